@@ -0,0 +1,116 @@
+// Package cli is the command line the front ends of the rpg share: the flags for the maps, the
+// seed, networking, recording and replaying, and starting a game or a client from them.
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"gameswithgo/rpg/game"
+	"gameswithgo/rpg/game/gen"
+	"gameswithgo/rpg/netplay"
+	"os"
+	"time"
+)
+
+// how long a replay waits between the recorded inputs
+const replayStepDelay = 100 * time.Millisecond
+
+// Flags are the values of the shared flags once flag.Parse has run
+type Flags struct {
+	MapDir        string
+	WorldFile     string
+	DefsFile      string
+	Seed          int64
+	StrictCorners bool
+	Addr          string
+	Name          string
+	Record        string
+	Replay        string
+
+	rec *game.Recording
+}
+
+// Register adds the shared flags to the command line, addrUsage tells what the front end does
+// with -addr. Call it before flag.Parse.
+func Register(addrUsage string) *Flags {
+	f := &Flags{}
+	flag.StringVar(&f.MapDir, "maps", "", "directory with the *.map files and the world file (default: built-in maps)")
+	flag.StringVar(&f.WorldFile, "world", "", "name of the world file inside the map directory (default: world)")
+	flag.StringVar(&f.DefsFile, "defs", "", "name of the monster and item definitions inside the map directory (default: definitions.json)")
+	flag.Int64Var(&f.Seed, "seed", 0, "seed for the generated levels and the dice (default: random)")
+	flag.BoolVar(&f.StrictCorners, "strict-corners", false, "forbid diagonal moves past the corner of a wall")
+	flag.StringVar(&f.Addr, "addr", "localhost:4000", addrUsage)
+	flag.StringVar(&f.Name, "name", "GoMan", "name of your character in a networked game")
+	flag.StringVar(&f.Record, "record", "", "write every input to this file, for replaying the game with -replay")
+	flag.StringVar(&f.Replay, "replay", "", "play back a recording made with -record before handing over, the seed and maps come from the recording")
+	return f
+}
+
+// Options are the options the game starts with, taken from the recording when there is one to
+// replay. Seed is set to the seed in use afterwards.
+func (f *Flags) Options() game.Options {
+	if f.Seed == 0 {
+		f.Seed = time.Now().UnixNano()
+	}
+	if f.Replay == "" {
+		return game.Options{MapDir: f.MapDir, WorldFile: f.WorldFile, DefsFile: f.DefsFile, Generator: gen.New(f.Seed), Seed: f.Seed,
+			StrictCorners: f.StrictCorners}
+	}
+	rec, err := game.ReadRecordingFile(f.Replay)
+	if err != nil {
+		fmt.Println(err)
+		if rec == nil {
+			os.Exit(1)
+		}
+	}
+	f.rec = rec
+	f.Seed = rec.Seed
+	opts := rec.Options()
+	opts.Generator = gen.New(rec.Seed)
+	return opts
+}
+
+// NewGame starts a game for the given number of windows and records it if asked to, the replay
+// doesn't start before StartReplay
+func (f *Flags) NewGame(windows int, opts game.Options) *game.Game {
+	rpg, err := game.NewGame(windows, opts)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if f.Record != "" {
+		if err := rpg.RecordFile(f.Record); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+	return rpg
+}
+
+// StartReplay plays the recording given with -replay on rpg, if there is one
+func (f *Flags) StartReplay(rpg *game.Game) {
+	if f.rec != nil {
+		rpg.StartReplay(f.rec, replayStepDelay)
+	}
+}
+
+// Join connects to the server at -addr as -name and keeps the client running in the background,
+// the maps and definitions have to match the server's
+func (f *Flags) Join(opts game.Options) (*netplay.Client, *game.Definitions) {
+	defs, err := opts.Definitions()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	client, err := netplay.Dial(f.Addr, f.Name, defs)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	go func() {
+		if err := client.Run(); err != nil {
+			fmt.Fprintln(os.Stderr, "connection lost:", err)
+		}
+	}()
+	return client, defs
+}
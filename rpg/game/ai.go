@@ -0,0 +1,162 @@
+package game
+
+import "math/rand"
+
+// Behaviour decides what a monster does when it is due, Act takes a single action and returns its cost
+type Behaviour interface {
+	Act(m *Monster, level *Level) float64
+}
+
+type AIState int
+
+const (
+	Sleeping AIState = iota
+	Wandering
+	Chasing
+	Fleeing
+	Returning
+)
+
+func (s AIState) String() string {
+	switch s {
+	case Sleeping:
+		return "sleeping"
+	case Wandering:
+		return "wandering"
+	case Chasing:
+		return "chasing"
+	case Fleeing:
+		return "fleeing"
+	case Returning:
+		return "returning"
+	}
+	return "confused"
+}
+
+// StateMachine is the behaviour shared by the ordinary monsters. Without the player in sight it
+// idles, once it sees the player it gives chase, runs away when badly hurt and, if it is a guard,
+// walks back to its post after losing track of the player.
+type StateMachine struct {
+	Idle      AIState // Sleeping or Wandering
+	FleeBelow float64 // fraction of max hitpoints below which the monster runs, 0 never runs
+	Guard     bool
+}
+
+func (sm StateMachine) Act(m *Monster, level *Level) float64 {
+	player := level.Player
+	sees := m.CanSee(level, player.Pos)
+	if sees {
+		m.LastSeen = player.Pos
+	}
+
+	hurt := sm.FleeBelow > 0 && m.MaxHitpoints > 0 &&
+		float64(m.Hitpoints) < sm.FleeBelow*float64(m.MaxHitpoints)
+	switch {
+	case sees && hurt:
+		m.State = Fleeing
+	case sees:
+		m.State = Chasing
+	case m.State == Fleeing:
+		m.State = sm.afterLosingPlayer(m)
+	}
+
+	switch m.State {
+	case Chasing:
+		if m.Pos == m.LastSeen {
+			m.State = sm.afterLosingPlayer(m)
+			return m.Pass()
+		}
+		return m.stepTowards(m.LastSeen, level)
+	case Fleeing:
+		return m.flee(level)
+	case Returning:
+		if m.Pos == m.Post {
+			m.State = sm.Idle
+			return m.Pass()
+		}
+		return m.stepTowards(m.Post, level)
+	case Wandering:
+		return m.wander(level)
+	}
+	return m.Pass()
+}
+
+func (sm StateMachine) afterLosingPlayer(m *Monster) AIState {
+	if sm.Guard && m.Pos != m.Post {
+		return Returning
+	}
+	return sm.Idle
+}
+
+// Disturb wakes the monster up when it gets hit, so sleepers don't get killed in their sleep
+func (m *Monster) Disturb(level *Level) {
+	if m.State == Sleeping || m.State == Wandering || m.State == Returning {
+		m.State = Chasing
+		m.LastSeen = level.Player.Pos
+	}
+}
+
+// CanSee tells whether pos is within the monster's sight range and not hidden behind walls or closed doors
+func (m *Monster) CanSee(level *Level, pos Pos) bool {
+	return level.CanSeeFrom(m.Pos, pos, m.Stats().SightRange)
+}
+
+// stepTowards moves one step along the shortest path to goal, attacking the player if it is in the way
+func (m *Monster) stepTowards(goal Pos, level *Level) float64 {
+	positions := level.astar(m.Pos, goal)
+	if len(positions) < 2 {
+		return m.Pass()
+	}
+	return m.Move(positions[1], level)
+}
+
+// flee steps to the neighbour furthest from the player, a cornered monster fights back
+func (m *Monster) flee(level *Level) float64 {
+	playerPos := level.Player.Pos
+	best := m.Pos
+	bestDist := distanceSq(m.Pos, playerPos)
+	for _, next := range getNeighbors(level, m.Pos) {
+		if level.PlayerAt(next) != nil {
+			continue
+		}
+		if d := distanceSq(next, playerPos); d > bestDist {
+			best, bestDist = next, d
+		}
+	}
+	if best == m.Pos {
+		if distanceSq(m.Pos, playerPos) <= 2 && level.canStep(m.Pos, playerPos) {
+			return m.Move(playerPos, level)
+		}
+		return m.Pass()
+	}
+	return m.Move(best, level)
+}
+
+func (m *Monster) wander(level *Level) float64 {
+	var options []Pos
+	for _, next := range getNeighbors(level, m.Pos) {
+		if level.PlayerAt(next) == nil {
+			options = append(options, next)
+		}
+	}
+	r := level.random()
+	// wandering monsters dawdle every now and then instead of pacing non-stop
+	if len(options) == 0 || r.Intn(3) == 0 {
+		return m.Pass()
+	}
+	return m.Move(options[r.Intn(len(options))], level)
+}
+
+// random returns the game's dice, a level that isn't part of a game yet rolls its own
+func (level *Level) random() *rand.Rand {
+	if level.rng == nil {
+		level.rng = rand.New(rand.NewSource(1))
+	}
+	return level.rng
+}
+
+func distanceSq(a, b Pos) int {
+	dx := a.X - b.X
+	dy := a.Y - b.Y
+	return dx*dx + dy*dy
+}
@@ -0,0 +1,70 @@
+package game
+
+import "strconv"
+
+// Every attack rolls a d100: below critChance it is a critical hit, below the hit chance a normal one and
+// anything else misses. The hit chance starts at baseHitChance and drops by one for every point of the
+// defender's defense, a hit does between half and all of the attacker's attack and a critical twice that.
+const (
+	baseHitChance = 90
+	minHitChance  = 10
+	critChance    = 5
+	critFactor    = 2
+)
+
+type AttackResult int
+
+const (
+	Missed AttackResult = iota
+	Struck
+	CriticalHit
+)
+
+// Attack lets c1 attack c2 and logs how it went. If c2 survives a hit and fights back, it strikes at c1
+// right away without spending any action points, a counter attack is never countered itself.
+func (level *Level) Attack(c1, c2 *Character) AttackResult {
+	result := level.strike(c1, c2)
+	if result != Missed && c2.Hitpoints > 0 && c2.CounterAttack {
+		level.AddMessage(Combat, c2.Name+" Strikes back", c2.ID, c1.ID)
+		level.strike(c2, c1)
+	}
+	return result
+}
+
+// strike resolves a single blow of c1 against c2
+func (level *Level) strike(c1, c2 *Character) AttackResult {
+	attack := c1.Stats().Attack
+	hitChance := baseHitChance - c2.Stats().Defense
+	if hitChance < minHitChance {
+		hitChance = minHitChance
+	}
+
+	r := level.random()
+	roll := r.Intn(100)
+	result := Missed
+	switch {
+	case roll < critChance:
+		result = CriticalHit
+	case roll < hitChance:
+		result = Struck
+	}
+	if result == Missed {
+		level.AddMessage(Combat, c1.Name+" Missed "+c2.Name, c1.ID, c2.ID)
+		return Missed
+	}
+
+	damage := (attack+1)/2 + r.Intn(attack/2+1)
+	if result == CriticalHit {
+		damage = attack * critFactor
+	}
+	c2.Hitpoints -= damage
+	switch {
+	case c2.Hitpoints <= 0:
+		level.AddMessage(Combat, c1.Name+" Killed "+c2.Name, c1.ID, c2.ID)
+	case result == CriticalHit:
+		level.AddMessage(Combat, c1.Name+" Critically hit "+c2.Name+" for "+strconv.Itoa(damage), c1.ID, c2.ID)
+	default:
+		level.AddMessage(Combat, c1.Name+" Attacked "+c2.Name+" for "+strconv.Itoa(damage), c1.ID, c2.ID)
+	}
+	return result
+}
@@ -0,0 +1,432 @@
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/rand"
+	"strings"
+	"unicode/utf8"
+)
+
+// Definitions describe every kind of monster and item the maps and the generator can place.
+// They are read from definitions.json next to the maps, see rpg/game/maps/definitions.json.
+type Definitions struct {
+	Monsters []*MonsterDef `json:"monsters"` // weakest first, the generator picks later ones on deeper levels
+	Items    []*ItemDef    `json:"items"`
+	Terrain  []TerrainDef  `json:"terrain"`
+
+	monsterRunes map[rune]*MonsterDef
+	itemRunes    map[rune]*ItemDef
+	terrainCosts map[rune]float64
+}
+
+type MonsterDef struct {
+	Name       string        `json:"name"`
+	Rune       Glyph         `json:"rune"` // what the monster is drawn as in the map files
+	Tile       []int         `json:"tile"` // x,y in the tile atlas, if left out the ui looks the rune up in atlas-index.txt
+	Hitpoints  int           `json:"hitpoints"`
+	Strength   int           `json:"strength"`
+	Speed      float64       `json:"speed"`
+	SightRange int           `json:"sight"`
+	Behaviour  *BehaviourDef `json:"behaviour"`     // without one the monster runs straight at the player
+	Counter    bool          `json:"counterAttack"` // strikes back when hit
+	XP         int           `json:"xp"`            // awarded to the player for the kill
+	Loot       []LootDef     `json:"loot"`
+
+	behaviour Behaviour
+	loot      []lootItem
+}
+
+type lootItem struct {
+	item     *ItemDef
+	chance   float64
+	equipped bool
+}
+
+// BehaviourDef sets up the StateMachine a monster acts with
+type BehaviourDef struct {
+	Idle      string  `json:"idle"` // "sleeping" or "wandering"
+	FleeBelow float64 `json:"fleeBelow"`
+	Guard     bool    `json:"guard"`
+}
+
+// LootDef is an item a monster carries and drops when it dies
+type LootDef struct {
+	Item     string  `json:"item"`
+	Chance   float64 `json:"chance"`   // between 0 and 1, 0 or left out the monster always carries it
+	Equipped bool    `json:"equipped"` // worn by the monster, which fights with its mods, if the slot is free
+}
+
+type ItemDef struct {
+	Name   string  `json:"name"`
+	Type   string  `json:"type"`   // "weapon", "helmet", "armor", "shield", "boots", "ring", "amulet", "consumable" or "other"
+	Effect string  `json:"effect"` // what using up a consumable does: "heal", "teleport" or "reveal"
+	Rune   Glyph   `json:"rune"`
+	Tile   []int   `json:"tile"`
+	Power  float64 `json:"power"` // how much a consumable heals
+	Mods   Stats   `json:"mods"`  // what equipping the item adds to attack, defense, speed and sight
+
+	typ    ItemType
+	effect Effect
+}
+
+// TerrainDef is what walking onto a map tile costs when pathfinding, tiles without one cost 1.
+// Monsters don't open doors, a cost on closed doors only steers the player's travel.
+type TerrainDef struct {
+	Rune Glyph   `json:"rune"` // one of the walkable map runes: . | / u d
+	Cost float64 `json:"cost"` // at least 1, or A* can't promise the shortest path
+}
+
+// Glyph is a rune written as a one character string in JSON
+type Glyph rune
+
+func (g *Glyph) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	r, size := utf8.DecodeRuneInString(s)
+	if size == 0 || size != len(s) {
+		return fmt.Errorf("rune %q must be a single character", s)
+	}
+	*g = Glyph(r)
+	return nil
+}
+
+func (g Glyph) MarshalJSON() ([]byte, error) {
+	return json.Marshal(string(rune(g)))
+}
+
+var itemTypes = map[string]ItemType{
+	"weapon":     Weapon,
+	"helmet":     Helmet,
+	"armor":      Armor,
+	"shield":     Shield,
+	"boots":      Boots,
+	"ring":       Ring,
+	"amulet":     Amulet,
+	"other":      Other,
+	"consumable": Consumable,
+}
+
+var effects = map[string]Effect{
+	"heal":     Heal,
+	"teleport": Teleport,
+	"reveal":   RevealMap,
+}
+
+var idleStates = map[string]AIState{
+	"sleeping":  Sleeping,
+	"wandering": Wandering,
+}
+
+// runes the map loader and the level scripts already give a meaning to
+const mapRunes = " \t#.|/ud@&+^"
+
+// map runes a character can walk onto
+const walkableRunes = ".|/ud"
+
+// DefError points at the monster or item definition that is wrong
+type DefError struct {
+	File string
+	Kind string // "monster", "item" or "terrain", empty if the whole file is wrong
+	Name string
+	Msg  string
+}
+
+func (e *DefError) Error() string {
+	msg := e.File
+	if e.Kind != "" {
+		msg += fmt.Sprintf(": %s %q", e.Kind, e.Name)
+	}
+	return msg + ": " + e.Msg
+}
+
+// DefaultDefinitions returns the monsters and items built into the binary
+func DefaultDefinitions() *Definitions {
+	file, err := DefaultMaps().Open("definitions.json")
+	if err != nil {
+		panic(err)
+	}
+	defer file.Close()
+	defs, err := LoadDefinitions(file)
+	if err != nil {
+		panic(err)
+	}
+	return defs
+}
+
+// LoadDefinitions reads and checks a definitions file, it returns the first problem it finds
+func LoadDefinitions(r io.Reader) (*Definitions, error) {
+	defs, errs := readDefinitions(r)
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+	return defs, nil
+}
+
+func readDefinitions(r io.Reader) (*Definitions, []error) {
+	defs := &Definitions{}
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(defs); err != nil {
+		return nil, []error{&DefError{Msg: err.Error()}}
+	}
+	errs := defs.resolve()
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return defs, nil
+}
+
+// resolve checks the definitions and links up loot, behaviours and runes
+func (defs *Definitions) resolve() []error {
+	var errs []error
+	defs.monsterRunes = make(map[rune]*MonsterDef)
+	defs.itemRunes = make(map[rune]*ItemDef)
+	taken := make(map[rune]string)
+	checkRune := func(kind, name string, r Glyph) error {
+		if r == 0 {
+			return &DefError{Kind: kind, Name: name, Msg: "no rune"}
+		}
+		for _, c := range mapRunes {
+			if rune(r) == c {
+				return &DefError{Kind: kind, Name: name, Msg: fmt.Sprintf("rune %q is a map tile", rune(r))}
+			}
+		}
+		if other, exists := taken[rune(r)]; exists {
+			return &DefError{Kind: kind, Name: name, Msg: fmt.Sprintf("rune %q already used by %s", rune(r), other)}
+		}
+		taken[rune(r)] = name
+		return nil
+	}
+	checkTile := func(kind, name string, tile []int) error {
+		if len(tile) != 0 && len(tile) != 2 {
+			return &DefError{Kind: kind, Name: name, Msg: "tile must be [x, y]"}
+		}
+		return nil
+	}
+
+	itemNames := make(map[string]*ItemDef)
+	for _, def := range defs.Items {
+		if def.Name == "" {
+			errs = append(errs, &DefError{Kind: "item", Msg: "no name"})
+			continue
+		}
+		if itemNames[def.Name] != nil {
+			errs = append(errs, &DefError{Kind: "item", Name: def.Name, Msg: "defined twice"})
+			continue
+		}
+		itemNames[def.Name] = def
+		typ, ok := itemTypes[def.Type]
+		if !ok {
+			errs = append(errs, &DefError{Kind: "item", Name: def.Name, Msg: fmt.Sprintf("unknown type %q", def.Type)})
+		}
+		def.typ = typ
+		effect, ok := effects[def.Effect]
+		switch {
+		case typ == Consumable && !ok:
+			errs = append(errs, &DefError{Kind: "item", Name: def.Name, Msg: fmt.Sprintf("unknown effect %q", def.Effect)})
+		case typ != Consumable && def.Effect != "":
+			errs = append(errs, &DefError{Kind: "item", Name: def.Name, Msg: "only consumables have an effect"})
+		case typ != Consumable && def.Power != 0:
+			errs = append(errs, &DefError{Kind: "item", Name: def.Name, Msg: "only consumables have power, equipment has mods"})
+		case typ == Consumable && def.Mods != Stats{}:
+			errs = append(errs, &DefError{Kind: "item", Name: def.Name, Msg: "consumables can't be equipped, they have no mods"})
+		}
+		def.effect = effect
+		if err := checkRune("item", def.Name, def.Rune); err != nil {
+			errs = append(errs, err)
+		} else {
+			defs.itemRunes[rune(def.Rune)] = def
+		}
+		if err := checkTile("item", def.Name, def.Tile); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	monsterNames := make(map[string]bool)
+	for _, def := range defs.Monsters {
+		if def.Name == "" {
+			errs = append(errs, &DefError{Kind: "monster", Msg: "no name"})
+			continue
+		}
+		if monsterNames[def.Name] {
+			errs = append(errs, &DefError{Kind: "monster", Name: def.Name, Msg: "defined twice"})
+			continue
+		}
+		monsterNames[def.Name] = true
+		if err := checkRune("monster", def.Name, def.Rune); err != nil {
+			errs = append(errs, err)
+		} else {
+			defs.monsterRunes[rune(def.Rune)] = def
+		}
+		if err := checkTile("monster", def.Name, def.Tile); err != nil {
+			errs = append(errs, err)
+		}
+		if def.Hitpoints <= 0 {
+			errs = append(errs, &DefError{Kind: "monster", Name: def.Name, Msg: "hitpoints must be positive"})
+		}
+		if def.XP < 0 {
+			errs = append(errs, &DefError{Kind: "monster", Name: def.Name, Msg: "xp can't be negative"})
+		}
+		if def.Speed <= 0 {
+			errs = append(errs, &DefError{Kind: "monster", Name: def.Name, Msg: "speed must be positive"})
+		}
+		if def.Behaviour != nil {
+			idle, ok := idleStates[def.Behaviour.Idle]
+			if !ok {
+				errs = append(errs, &DefError{Kind: "monster", Name: def.Name,
+					Msg: fmt.Sprintf("idle must be sleeping or wandering, not %q", def.Behaviour.Idle)})
+			}
+			def.behaviour = StateMachine{Idle: idle, FleeBelow: def.Behaviour.FleeBelow, Guard: def.Behaviour.Guard}
+		}
+		def.loot = nil
+		for _, loot := range def.Loot {
+			item := itemNames[loot.Item]
+			if item == nil {
+				errs = append(errs, &DefError{Kind: "monster", Name: def.Name, Msg: fmt.Sprintf("unknown loot item %q", loot.Item)})
+				continue
+			}
+			if loot.Chance < 0 || loot.Chance > 1 {
+				errs = append(errs, &DefError{Kind: "monster", Name: def.Name, Msg: fmt.Sprintf("chance of %s must be between 0 and 1", loot.Item)})
+			}
+			if loot.Equipped && (item.typ == Consumable || item.typ == Other) {
+				errs = append(errs, &DefError{Kind: "monster", Name: def.Name, Msg: fmt.Sprintf("%s can't be equipped", loot.Item)})
+			}
+			def.loot = append(def.loot, lootItem{item, loot.Chance, loot.Equipped})
+		}
+	}
+
+	defs.terrainCosts = make(map[rune]float64)
+	for _, def := range defs.Terrain {
+		name := string(rune(def.Rune))
+		switch {
+		case !strings.ContainsRune(walkableRunes, rune(def.Rune)):
+			errs = append(errs, &DefError{Kind: "terrain", Name: name, Msg: "not a walkable map tile"})
+		case defs.terrainCosts[rune(def.Rune)] != 0:
+			errs = append(errs, &DefError{Kind: "terrain", Name: name, Msg: "defined twice"})
+		case def.Cost < 1:
+			errs = append(errs, &DefError{Kind: "terrain", Name: name, Msg: "cost must be at least 1"})
+		default:
+			defs.terrainCosts[rune(def.Rune)] = def.Cost
+		}
+	}
+	return errs
+}
+
+func (defs *Definitions) MonsterByRune(r rune) *MonsterDef {
+	return defs.monsterRunes[r]
+}
+
+func (defs *Definitions) ItemByRune(r rune) *ItemDef {
+	return defs.itemRunes[r]
+}
+
+func (defs *Definitions) Monster(name string) *MonsterDef {
+	for _, def := range defs.Monsters {
+		if def.Name == name {
+			return def
+		}
+	}
+	return nil
+}
+
+func (defs *Definitions) Item(name string) *ItemDef {
+	for _, def := range defs.Items {
+		if def.Name == name {
+			return def
+		}
+	}
+	return nil
+}
+
+// behaviour looks up how monsters called name act, used to hook loaded monsters back up
+func (defs *Definitions) behaviour(name string) Behaviour {
+	def := defs.Monster(name)
+	if def == nil {
+		return nil
+	}
+	return def.behaviour
+}
+
+// New makes a monster at p, r decides which of its loot it carries
+func (def *MonsterDef) New(p Pos, r *rand.Rand) *Monster {
+	m := &Monster{
+		Character: Character{
+			Entity: Entity{
+				Pos:  p,
+				Name: def.Name,
+				Rune: rune(def.Rune),
+			},
+			Hitpoints:     def.Hitpoints,
+			MaxHitpoints:  def.Hitpoints,
+			Strength:      def.Strength,
+			Speed:         def.Speed,
+			SightRange:    def.SightRange,
+			CounterAttack: def.Counter,
+		},
+		Behaviour: def.behaviour,
+		Post:      p,
+		XP:        def.XP,
+	}
+	if def.Behaviour != nil {
+		m.State = idleStates[def.Behaviour.Idle]
+	}
+	for _, loot := range def.loot {
+		// only roll for loot that isn't certain, so certain loot doesn't shift the dice for everything else
+		if loot.chance == 0 || loot.chance >= 1 || r.Float64() < loot.chance {
+			item := loot.item.New(p)
+			if slot := m.slotFor(item); loot.equipped && slot != nil && *slot == nil {
+				*slot = item
+			} else {
+				m.Items = append(m.Items, item)
+			}
+		}
+	}
+	return m
+}
+
+func (def *ItemDef) New(p Pos) *Item {
+	item := &Item{Typ: def.typ, Entity: Entity{Pos: p, Name: def.Name, Rune: rune(def.Rune)}, Power: def.Power, Mods: def.Mods, Effect: def.effect}
+	if def.typ == Consumable {
+		item.Count = 1
+	}
+	return item
+}
+
+// Definitions reads the definitions file from the map directory, the built-in definitions are
+// used if the directory doesn't have one and no file was asked for by name
+func (opts Options) Definitions() (*Definitions, error) {
+	defs, errs := opts.readDefinitions()
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+	return defs, nil
+}
+
+func (opts Options) readDefinitions() (*Definitions, []error) {
+	name := opts.DefsFile
+	if name == "" {
+		name = "definitions.json"
+	}
+	file, err := opts.maps().Open(name)
+	if errors.Is(err, fs.ErrNotExist) && opts.DefsFile == "" {
+		return DefaultDefinitions(), nil
+	}
+	if err != nil {
+		return nil, []error{err}
+	}
+	defer file.Close()
+	defs, errs := readDefinitions(file)
+	for _, err := range errs {
+		var defErr *DefError
+		if errors.As(err, &defErr) {
+			defErr.File = name
+		}
+	}
+	return defs, errs
+}
@@ -0,0 +1,40 @@
+package game
+
+import "math/rand"
+
+// dice is the source of the game's rng. It counts its draws, so a save can put the dice back
+// where they were by drawing as often from the same seed again.
+type dice struct {
+	rand.Source64
+	seed  int64
+	draws uint64
+}
+
+func newDice(seed int64) *dice {
+	return &dice{Source64: rand.NewSource(seed).(rand.Source64), seed: seed}
+}
+
+// rolledDice are dice of seed that were already drawn from draws times
+func rolledDice(seed int64, draws uint64) *dice {
+	d := newDice(seed)
+	for d.draws < draws {
+		d.Int63()
+	}
+	return d
+}
+
+func (d *dice) Int63() int64 {
+	d.draws++
+	return d.Source64.Int63()
+}
+
+func (d *dice) Uint64() uint64 {
+	d.draws++
+	return d.Source64.Uint64()
+}
+
+func (d *dice) Seed(seed int64) {
+	d.Source64.Seed(seed)
+	d.seed = seed
+	d.draws = 0
+}
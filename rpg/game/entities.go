@@ -0,0 +1,228 @@
+package game
+
+import (
+	"fmt"
+	"sort"
+)
+
+// EntityID names an item, monster or player for the whole game. It stays the same while the
+// entity moves about, is saved with it and means the same thing in a replay or on a network
+// client, which a pointer doesn't.
+type EntityID int64
+
+// EntityError is what an input gets back for an entity that isn't where the input expects it
+type EntityError struct {
+	ID  EntityID
+	Msg string
+}
+
+func (e *EntityError) Error() string {
+	return fmt.Sprintf("entity %d %s", e.ID, e.Msg)
+}
+
+// refuse tells the player why an input didn't work, it takes no time
+func (game *Game) refuse(err error) float64 {
+	game.CurrentLevel.AddMessage(System, err.Error())
+	return 0
+}
+
+// idSource hands out the IDs of a game, all of its levels share one
+type idSource struct {
+	last EntityID
+}
+
+func (ids *idSource) next() EntityID {
+	ids.last++
+	return ids.last
+}
+
+// registry finds the monsters of a level and the items lying on it or carried by its monsters by
+// ID. The party moves between levels and keeps its own things, it is looked up through
+// Level.Players instead.
+type registry struct {
+	monsters map[EntityID]*Monster
+	items    map[EntityID]*Item
+}
+
+func (level *Level) PlayerByID(id EntityID) *Player {
+	for _, p := range level.party() {
+		if p.ID == id {
+			return p
+		}
+	}
+	return nil
+}
+
+func (level *Level) MonsterByID(id EntityID) *Monster {
+	return level.registry.monsters[id]
+}
+
+// ItemByID finds an item on the level, on the ground or carried by anyone
+func (level *Level) ItemByID(id EntityID) *Item {
+	if item := level.registry.items[id]; item != nil {
+		return item
+	}
+	for _, p := range level.party() {
+		if item := p.item(id); item != nil {
+			return item
+		}
+	}
+	return nil
+}
+
+// item finds an item in the character's backpack or equipment
+func (c *Character) item(id EntityID) *Item {
+	for _, item := range c.Items {
+		if item.ID == id {
+			return item
+		}
+	}
+	for _, item := range c.Equipment() {
+		if item.ID == id {
+			return item
+		}
+	}
+	return nil
+}
+
+// newID hands out the next ID of the level's game, a level that isn't part of a game yet leaves
+// its entities without one until it joins, see Game.adopt
+func (level *Level) newID() EntityID {
+	if level.ids == nil {
+		return 0
+	}
+	return level.ids.next()
+}
+
+// AddMonster puts a monster that just turned up on the level, with IDs for it, its loot and its
+// equipment
+func (level *Level) AddMonster(m *Monster) {
+	level.Monsters[m.Pos] = m
+	if level.ids == nil {
+		return
+	}
+	m.ID = level.newID()
+	level.registry.monsters[m.ID] = m
+	for _, item := range m.Items {
+		item.ID = level.newID()
+		level.registerItem(item)
+	}
+	for _, item := range m.Equipment() {
+		item.ID = level.newID()
+		level.registerItem(item)
+	}
+}
+
+// removeMonster takes a monster off the level, what it carried stays behind with it
+func (level *Level) removeMonster(m *Monster) {
+	delete(level.Monsters, m.Pos)
+	delete(level.registry.monsters, m.ID)
+}
+
+// moveMonster keeps the level's monsters indexed by position as m walks to pos
+func (level *Level) moveMonster(m *Monster, pos Pos) {
+	delete(level.Monsters, m.Pos)
+	level.Monsters[pos] = m
+	m.Pos = pos
+}
+
+// AddItem lays an item that just turned up on the ground at its position
+func (level *Level) AddItem(item *Item) {
+	item.ID = level.newID()
+	level.putItem(item)
+}
+
+// putItem lays an item on the ground at its position, it belongs to the level from now on
+func (level *Level) putItem(item *Item) {
+	level.Items[item.Pos] = append(level.Items[item.Pos], item)
+	level.registerItem(item)
+}
+
+func (level *Level) registerItem(item *Item) {
+	if level.ids != nil {
+		level.registry.items[item.ID] = item
+	}
+}
+
+// adopt makes the levels part of the game: their players, monsters and items get IDs, in a fixed
+// order so the same game always hands out the same IDs, and the levels start handing out the game's
+// IDs to whatever turns up later. Levels that are already part of the game are left alone.
+func (game *Game) adopt() {
+	names := make([]string, 0, len(game.Levels))
+	for name, level := range game.Levels {
+		if level.ids == nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	// a loaded game goes on after the highest ID in use
+	for _, name := range names {
+		game.Levels[name].eachEntity(func(e *Entity) {
+			if e.ID > game.ids.last {
+				game.ids.last = e.ID
+			}
+		})
+	}
+	for _, name := range names {
+		level := game.Levels[name]
+		level.ids = game.ids
+		level.eachEntity(func(e *Entity) {
+			if e.ID == 0 {
+				e.ID = game.ids.next()
+			}
+		})
+		level.index()
+	}
+}
+
+// index fills the level's registry from what is on it
+func (level *Level) index() {
+	level.registry = registry{make(map[EntityID]*Monster), make(map[EntityID]*Item)}
+	for _, m := range level.Monsters {
+		level.registry.monsters[m.ID] = m
+		for _, item := range m.Items {
+			level.registry.items[item.ID] = item
+		}
+		for _, item := range m.Equipment() {
+			level.registry.items[item.ID] = item
+		}
+	}
+	for _, items := range level.Items {
+		for _, item := range items {
+			level.registry.items[item.ID] = item
+		}
+	}
+}
+
+// eachEntity calls f for everything on the level in a fixed order: the party, the monsters top to
+// bottom and then the items on the ground, carried things right after their carrier
+func (level *Level) eachEntity(f func(e *Entity)) {
+	character := func(c *Character) {
+		f(&c.Entity)
+		for _, item := range c.Items {
+			f(&item.Entity)
+		}
+		for _, item := range c.Equipment() {
+			f(&item.Entity)
+		}
+	}
+	for _, p := range level.party() {
+		character(&p.Character)
+	}
+	for _, m := range level.SortedMonsters() {
+		character(&m.Character)
+	}
+	positions := make([]Pos, 0, len(level.Items))
+	for pos := range level.Items {
+		positions = append(positions, pos)
+	}
+	sort.Slice(positions, func(i, j int) bool {
+		return positions[i].Less(positions[j])
+	})
+	for _, pos := range positions {
+		for _, item := range level.Items[pos] {
+			f(&item.Entity)
+		}
+	}
+}
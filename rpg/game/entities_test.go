@@ -0,0 +1,77 @@
+package game
+
+import "testing"
+
+func TestEntityIDs(t *testing.T) {
+	game, err := NewGame(0, Options{Seed: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[EntityID]bool)
+	for _, level := range game.Levels {
+		level.eachEntity(func(e *Entity) {
+			if e.ID == game.Players[0].ID {
+				return // the party is on every level
+			}
+			if e.ID == 0 || seen[e.ID] {
+				t.Errorf("%s has ID %d, handed out twice or not at all", e.Name, e.ID)
+			}
+			seen[e.ID] = true
+		})
+	}
+
+	// the ambush plate spawns a rat wielding a sword, they can be found at once
+	ratDef := game.Defs.Monster("Rat")
+	ratDef.loot = append(ratDef.loot, lootItem{game.Defs.Item("Sword"), 0, true})
+	level := game.Levels["level2"]
+	game.CurrentLevel = level
+	p := level.Player
+	p.Pos = Pos{5, 6}
+	game.fire(level.Triggers[Pos{5, 6}])
+	rat := level.Monsters[Pos{2, 7}]
+	if rat == nil {
+		t.Fatal("the ambush spawned no rat")
+	}
+	if rat.Weapon == nil {
+		t.Fatal("the spawned rat wields nothing")
+	}
+	for _, e := range []*Entity{&rat.Entity, &rat.Items[0].Entity, &rat.Weapon.Entity} {
+		if e.ID == 0 || seen[e.ID] {
+			t.Errorf("spawned %s has ID %d", e.Name, e.ID)
+		}
+		seen[e.ID] = true
+	}
+	if level.MonsterByID(rat.ID) != rat {
+		t.Error("spawned rat can't be found by its ID")
+	}
+	if level.ItemByID(rat.Weapon.ID) != rat.Weapon {
+		t.Error("the spawned rat's sword can't be found by its ID")
+	}
+
+	sword := rat.Weapon
+	rat.Kill(level)
+	if level.MonsterByID(rat.ID) != nil {
+		t.Error("killed rat can still be found by its ID")
+	}
+	if level.ItemByID(sword.ID) != sword || len(level.Items[rat.Pos]) != 2 {
+		t.Errorf("the killed rat should leave its helmet and sword behind, left %v", level.Items[rat.Pos])
+	}
+
+	potion := &Item{Typ: Consumable, Entity: Entity{ID: game.ids.next(), Name: "Potion"}, Count: 1}
+	p.Items = append(p.Items, potion)
+	if level.ItemByID(potion.ID) != potion {
+		t.Error("carried potion can't be found by its ID")
+	}
+	if err := level.DropItem(potion.ID, &p.Character); err != nil {
+		t.Fatal(err)
+	}
+	if level.registry.items[potion.ID] != potion {
+		t.Error("dropped potion isn't indexed by the level")
+	}
+	if err := level.MoveItem(potion.ID, &p.Character); err != nil {
+		t.Fatal(err)
+	}
+	if level.registry.items[potion.ID] != nil || level.ItemByID(potion.ID) != potion {
+		t.Error("picked up potion is still indexed as lying on the ground")
+	}
+}
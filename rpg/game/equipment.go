@@ -0,0 +1,133 @@
+package game
+
+// Stats are the numbers a character fights, moves and looks around with. Items carry them as
+// modifiers that are added on top of the character's own when the item is equipped.
+type Stats struct {
+	Attack     int     `json:"attack"`
+	Defense    int     `json:"defense"` // taken off an attacker's chance to hit, in percent
+	Speed      float64 `json:"speed"`
+	SightRange int     `json:"sight"`
+}
+
+const minSpeed = .25 // heavy gear slows a character down but never stops it
+
+// Equipment lists everything the character is wearing or wielding, empty slots left out
+func (c *Character) Equipment() []*Item {
+	var equipment []*Item
+	for _, item := range []*Item{c.Helmet, c.Amulet, c.Armor, c.Weapon, c.Shield, c.LeftRing, c.RightRing, c.Boots} {
+		if item != nil {
+			equipment = append(equipment, item)
+		}
+	}
+	return equipment
+}
+
+// Stats adds up the character's own numbers and the modifiers of its equipment
+func (c *Character) Stats() Stats {
+	stats := Stats{Attack: c.Strength, Speed: c.Speed, SightRange: c.SightRange}
+	for _, item := range c.Equipment() {
+		stats.Attack += item.Mods.Attack
+		stats.Defense += item.Mods.Defense
+		stats.Speed += item.Mods.Speed
+		stats.SightRange += item.Mods.SightRange
+	}
+	if stats.Attack < 0 {
+		stats.Attack = 0
+	}
+	if stats.Speed < minSpeed && c.Speed > 0 {
+		stats.Speed = minSpeed
+	}
+	if stats.SightRange < 1 {
+		stats.SightRange = 1
+	}
+	return stats
+}
+
+// Slot is a place on a character an item can be worn in, AnySlot leaves it to the game to pick
+type Slot int
+
+const (
+	AnySlot Slot = iota
+	HelmetSlot
+	AmuletSlot
+	ArmorSlot
+	WeaponSlot
+	ShieldSlot
+	LeftRingSlot
+	RightRingSlot
+	BootsSlot
+)
+
+// slot points at the slot s and tells which type of item fits in it
+func (c *Character) slot(s Slot) (**Item, ItemType) {
+	switch s {
+	case HelmetSlot:
+		return &c.Helmet, Helmet
+	case AmuletSlot:
+		return &c.Amulet, Amulet
+	case ArmorSlot:
+		return &c.Armor, Armor
+	case WeaponSlot:
+		return &c.Weapon, Weapon
+	case ShieldSlot:
+		return &c.Shield, Shield
+	case LeftRingSlot:
+		return &c.LeftRing, Ring
+	case RightRingSlot:
+		return &c.RightRing, Ring
+	case BootsSlot:
+		return &c.Boots, Boots
+	}
+	return nil, 0
+}
+
+// slotFor points at the slot item goes into, nil if it can't be worn. A ring goes on the
+// second hand only while the first one already has a ring and the second one doesn't.
+func (c *Character) slotFor(item *Item) **Item {
+	switch item.Typ {
+	case Helmet:
+		return &c.Helmet
+	case Weapon:
+		return &c.Weapon
+	case Armor:
+		return &c.Armor
+	case Shield:
+		return &c.Shield
+	case Boots:
+		return &c.Boots
+	case Amulet:
+		return &c.Amulet
+	case Ring:
+		if c.LeftRing != nil && c.RightRing == nil {
+			return &c.RightRing
+		}
+		return &c.LeftRing
+	}
+	return nil
+}
+
+// equip moves an item from the backpack into slot s, or the slot it fits in for AnySlot, whatever
+// was in the slot goes back into the backpack
+func equip(c *Character, id EntityID, s Slot) error {
+	for i, item := range c.Items {
+		if item.ID == id {
+			slot := c.slotFor(item)
+			if s != AnySlot {
+				var typ ItemType
+				if slot, typ = c.slot(s); slot == nil || typ != item.Typ {
+					return &EntityError{id, "doesn't fit in that slot"}
+				}
+			}
+			if slot == nil {
+				return &EntityError{id, "can't be worn"}
+			}
+			c.Items = append(c.Items[:i], c.Items[i+1:]...)
+			if *slot != nil {
+				c.Items = append(c.Items, *slot)
+			}
+			*slot = item
+			return nil
+		}
+	}
+	return &EntityError{id, "isn't in " + c.Name + "'s backpack"}
+}
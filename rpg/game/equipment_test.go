@@ -0,0 +1,40 @@
+package game
+
+import "testing"
+
+func TestEquipRingSlot(t *testing.T) {
+	ring := func(id EntityID) *Item {
+		return &Item{Typ: Ring, Entity: Entity{ID: id, Name: "Ring"}, Count: 1}
+	}
+	c := &Character{Entity: Entity{Name: "Ann"}}
+	c.Items = []*Item{ring(1), ring(2), ring(3), {Typ: Weapon, Entity: Entity{ID: 4, Name: "Sword"}, Count: 1}}
+
+	// the ring goes where it was put, even with the other hand free
+	if err := equip(c, 1, RightRingSlot); err != nil {
+		t.Fatal(err)
+	}
+	if c.RightRing == nil || c.RightRing.ID != 1 || c.LeftRing != nil {
+		t.Fatalf("ring 1 should be on the right hand, left %v right %v", c.LeftRing, c.RightRing)
+	}
+	if err := equip(c, 2, AnySlot); err != nil {
+		t.Fatal(err)
+	}
+	if c.LeftRing == nil || c.LeftRing.ID != 2 {
+		t.Fatalf("ring 2 should be on the free left hand, got %v", c.LeftRing)
+	}
+
+	// with both hands full the right ring can be swapped out
+	if err := equip(c, 3, RightRingSlot); err != nil {
+		t.Fatal(err)
+	}
+	if c.RightRing.ID != 3 || c.LeftRing.ID != 2 {
+		t.Errorf("ring 3 should replace the right ring, left %d right %d", c.LeftRing.ID, c.RightRing.ID)
+	}
+	if c.item(1) == nil || c.Items[len(c.Items)-1].ID != 1 {
+		t.Error("the swapped out ring should be back in the backpack")
+	}
+
+	if err := equip(c, 4, LeftRingSlot); err == nil {
+		t.Error("a sword went into a ring slot")
+	}
+}
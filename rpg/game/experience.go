@@ -0,0 +1,29 @@
+package game
+
+import "strconv"
+
+// what every level-up adds to the player, sight only grows on every other level
+const (
+	hitpointsPerLevel = 10
+	strengthPerLevel  = 2
+)
+
+// XPForLevel is the experience the player needs in total to reach a level: 20 for level 2, 60 for 3, 120 for 4 ...
+func XPForLevel(level int) int {
+	return 10 * level * (level - 1)
+}
+
+// GainXP adds experience and levels the player up as often as it has earned, every level-up is logged
+func (p *Player) GainXP(xp int, level *Level) {
+	p.XP += xp
+	for p.XP >= XPForLevel(p.ExpLevel+1) {
+		p.ExpLevel++
+		p.MaxHitpoints += hitpointsPerLevel
+		p.Hitpoints += hitpointsPerLevel
+		p.Strength += strengthPerLevel
+		if p.ExpLevel%2 == 1 {
+			p.SightRange++
+		}
+		level.AddMessage(Progress, p.Name+" reached level "+strconv.Itoa(p.ExpLevel), p.ID)
+	}
+}
@@ -0,0 +1,113 @@
+package game
+
+// FieldOfView calls visit for every position within radius of origin that can be seen from it, origin included.
+// It uses symmetric shadowcasting: each quadrant is scanned row by row moving away from the origin and
+// walls narrow the range of slopes the following rows are scanned in. If b is visited when looking from
+// a, a is visited when looking from b, so the player and the monsters always agree on who sees whom.
+// See https://www.albertford.com/shadowcasting/ for a walkthrough of the algorithm.
+func (level *Level) FieldOfView(origin Pos, radius int, visit func(Pos)) {
+	visit(origin)
+	for quadrant := 0; quadrant < 4; quadrant++ {
+		q := fovQuadrant{quadrant, origin}
+		level.scanRow(q, fovRow{1, fraction{-1, 1}, fraction{1, 1}}, radius, visit)
+	}
+}
+
+// CanSeeFrom tells whether to is within radius of from and in view of it
+func (level *Level) CanSeeFrom(from, to Pos, radius int) bool {
+	if distanceSq(from, to) > radius*radius {
+		return false
+	}
+	seen := false
+	level.FieldOfView(from, radius, func(pos Pos) {
+		if pos == to {
+			seen = true
+		}
+	})
+	return seen
+}
+
+// fraction keeps slopes exact, floats would make the symmetry check flaky on tile edges
+type fraction struct {
+	num, den int
+}
+
+func (f fraction) less(g fraction) bool {
+	return f.num*g.den < g.num*f.den
+}
+
+type fovRow struct {
+	depth      int
+	startSlope fraction
+	endSlope   fraction
+}
+
+// minCol rounds depth*startSlope half up, maxCol rounds depth*endSlope half down
+func (r fovRow) minCol() int {
+	return floorDiv(2*r.depth*r.startSlope.num+r.startSlope.den, 2*r.startSlope.den)
+}
+
+func (r fovRow) maxCol() int {
+	return -floorDiv(-(2*r.depth*r.endSlope.num - r.endSlope.den), 2*r.endSlope.den)
+}
+
+// isSymmetric is true for floor tiles whose centre lies inside the row's slopes
+func (r fovRow) isSymmetric(col int) bool {
+	return !(fraction{col, r.depth}).less(r.startSlope) && !r.endSlope.less(fraction{col, r.depth})
+}
+
+func tileSlope(depth, col int) fraction {
+	return fraction{2*col - 1, 2 * depth}
+}
+
+type fovQuadrant struct {
+	cardinal int // 0 north, 1 east, 2 south, 3 west
+	origin   Pos
+}
+
+func (q fovQuadrant) transform(depth, col int) Pos {
+	switch q.cardinal {
+	case 0:
+		return Pos{q.origin.X + col, q.origin.Y - depth}
+	case 1:
+		return Pos{q.origin.X + depth, q.origin.Y + col}
+	case 2:
+		return Pos{q.origin.X + col, q.origin.Y + depth}
+	default:
+		return Pos{q.origin.X - depth, q.origin.Y + col}
+	}
+}
+
+func (level *Level) scanRow(q fovQuadrant, row fovRow, radius int, visit func(Pos)) {
+	if row.depth > radius {
+		return
+	}
+	prevWall, hasPrev := false, false
+	for col := row.minCol(); col <= row.maxCol(); col++ {
+		pos := q.transform(row.depth, col)
+		wall := !canSeeThrough(level, pos)
+		if (wall || row.isSymmetric(col)) && inRange(level, pos) &&
+			row.depth*row.depth+col*col <= radius*radius {
+			visit(pos)
+		}
+		if hasPrev && prevWall && !wall {
+			row.startSlope = tileSlope(row.depth, col)
+		}
+		if hasPrev && !prevWall && wall {
+			next := fovRow{row.depth + 1, row.startSlope, tileSlope(row.depth, col)}
+			level.scanRow(q, next, radius, visit)
+		}
+		prevWall, hasPrev = wall, true
+	}
+	if hasPrev && !prevWall {
+		level.scanRow(q, fovRow{row.depth + 1, row.startSlope, row.endSlope}, radius, visit)
+	}
+}
+
+func floorDiv(a, b int) int {
+	q := a / b
+	if (a%b != 0) && ((a < 0) != (b < 0)) {
+		q--
+	}
+	return q
+}
@@ -0,0 +1,181 @@
+package game_test
+
+import (
+	"gameswithgo/rpg/game"
+	"gameswithgo/rpg/game/gen"
+	"math"
+	"testing"
+)
+
+func level1(tb testing.TB) *game.Level {
+	file, err := game.DefaultMaps().Open("level1.map")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer file.Close()
+	level, err := game.LoadLevel("level1", file, game.DefaultDefinitions())
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return level
+}
+
+func cave(tb testing.TB, size int) *game.Level {
+	config := gen.DefaultConfig()
+	config.Width, config.Height, config.Style = size, size, gen.Cave
+	level, _, err := gen.Generate(1, 2, config, &game.Player{}, game.DefaultDefinitions())
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return level
+}
+
+func floorTiles(level *game.Level) []game.Pos {
+	var floor []game.Pos
+	for y, row := range level.Map {
+		for x, tile := range row {
+			if tile.Rune == game.DirtFloor && tile.OverlayRune == game.Blank {
+				floor = append(floor, game.Pos{X: x, Y: y})
+			}
+		}
+	}
+	return floor
+}
+
+// fov is FieldOfView or rayFan
+type fov func(level *game.Level, origin game.Pos, radius int, visit func(game.Pos))
+
+func shadowcasting(level *game.Level, origin game.Pos, radius int, visit func(game.Pos)) {
+	level.FieldOfView(origin, radius, visit)
+}
+
+// rayFan is the field of view the game used before shadowcasting, a bresenham ray to every tile in
+// the sight circle that stops at the first wall. It is only kept to compare FieldOfView against.
+func rayFan(level *game.Level, origin game.Pos, radius int, visit func(game.Pos)) {
+	for y := origin.Y - radius; y <= origin.Y+radius; y++ {
+		for x := origin.X - radius; x <= origin.X+radius; x++ {
+			dx, dy := origin.X-x, origin.Y-y
+			if math.Sqrt(float64(dx*dx+dy*dy)) <= float64(radius) {
+				bresenham(level, origin, game.Pos{X: x, Y: y}, visit)
+			}
+		}
+	}
+}
+
+func bresenham(level *game.Level, start, end game.Pos, visit func(game.Pos)) {
+	steep := abs(end.Y-start.Y) > abs(end.X-start.X) // whether the line skews toward y
+	if steep {
+		start.X, start.Y = start.Y, start.X
+		end.X, end.Y = end.Y, end.X
+	}
+	deltaX, deltaY := abs(end.X-start.X), abs(end.Y-start.Y)
+	xstep, ystep := 1, 1
+	if start.X > end.X {
+		xstep = -1
+	}
+	if start.Y >= end.Y {
+		ystep = -1
+	}
+	err := 0
+	y := start.Y
+	for x := start.X; x != end.X; x += xstep {
+		pos := game.Pos{X: x, Y: y}
+		if steep {
+			pos = game.Pos{X: y, Y: x}
+		}
+		if pos.Y < 0 || pos.Y >= len(level.Map) || pos.X < 0 || pos.X >= len(level.Map[pos.Y]) {
+			return
+		}
+		visit(pos)
+		if !seeThrough(level.Map[pos.Y][pos.X]) {
+			return
+		}
+		err += deltaY
+		if 2*err >= deltaX {
+			y += ystep
+			err -= deltaX
+		}
+	}
+}
+
+func seeThrough(tile game.Tile) bool {
+	switch {
+	case tile.Rune == game.StoneWall, tile.Rune == game.Blank, tile.OverlayRune == game.ClosedDoor:
+		return false
+	}
+	return true
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func benchmarkFOV(b *testing.B, level *game.Level, radius int, f fov) {
+	origins := floorTiles(level)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		f(level, origins[i%len(origins)], radius, func(game.Pos) {})
+	}
+}
+
+func BenchmarkFOVLevel1(b *testing.B) {
+	benchmarkFOV(b, level1(b), 7, shadowcasting)
+}
+
+func BenchmarkFOVCave(b *testing.B) {
+	benchmarkFOV(b, cave(b, 200), 7, shadowcasting)
+}
+
+func BenchmarkFOVCaveFar(b *testing.B) {
+	benchmarkFOV(b, cave(b, 200), 20, shadowcasting)
+}
+
+// BenchmarkRayFan runs the ray fan FieldOfView replaced on the same levels and origins
+func BenchmarkRayFan(b *testing.B) {
+	b.Run("Level1", func(b *testing.B) { benchmarkFOV(b, level1(b), 7, rayFan) })
+	b.Run("Cave", func(b *testing.B) { benchmarkFOV(b, cave(b, 200), 7, rayFan) })
+	b.Run("CaveFar", func(b *testing.B) { benchmarkFOV(b, cave(b, 200), 20, rayFan) })
+}
+
+// asymmetric lists the pairs of floor tiles of level where a sees b but b doesn't see a
+func asymmetric(level *game.Level, radius int, f fov) [][2]game.Pos {
+	floor := floorTiles(level)
+	isFloor := make(map[game.Pos]bool)
+	views := make(map[game.Pos]map[game.Pos]bool)
+	for _, pos := range floor {
+		isFloor[pos] = true
+		seen := make(map[game.Pos]bool)
+		f(level, pos, radius, func(p game.Pos) { seen[p] = true })
+		views[pos] = seen
+	}
+	var pairs [][2]game.Pos
+	for _, a := range floor {
+		for b := range views[a] {
+			if isFloor[b] && !views[b][a] {
+				pairs = append(pairs, [2]game.Pos{a, b})
+			}
+		}
+	}
+	return pairs
+}
+
+// TestFOVSymmetric checks that no two floor tiles see each other one way only, neither on level1
+// nor in the twisty passages of a cave
+func TestFOVSymmetric(t *testing.T) {
+	for name, level := range map[string]*game.Level{"level1": level1(t), "cave": cave(t, 40)} {
+		for _, pair := range asymmetric(level, 7, shadowcasting) {
+			t.Errorf("%s: %v sees %v but not the other way round", name, pair[0], pair[1])
+		}
+	}
+}
+
+// TestRayFanAsymmetric shows what shadowcasting fixed: in the same cave the ray fan lets tiles
+// see each other one way only
+func TestRayFanAsymmetric(t *testing.T) {
+	if len(asymmetric(cave(t, 40), 7, rayFan)) == 0 {
+		t.Error("the ray fan came out symmetric in the cave")
+	}
+}
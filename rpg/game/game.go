@@ -3,12 +3,16 @@ package game
 import (
 	"bufio"
 	"encoding/csv"
+	"errors"
 	"fmt"
-	"math"
+	"io"
+	"io/fs"
+	"math/rand"
 	"os"
-	"path/filepath"
+	"path"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 type Game struct {
@@ -16,18 +20,91 @@ type Game struct {
 	InputChan    chan *Input
 	Levels       map[string]*Level
 	CurrentLevel *Level
+	Players      []*Player // the party, see AddPlayer
+	SavePath     string
+	Dead         bool
+	Defs         *Definitions
+	options      Options
+	rng          *rand.Rand        // the dice of every level, seeded from Options.Seed
+	dice         *dice             // the source of rng, counted for saving
+	spotted      map[*Monster]bool // monsters already in sight when the player set off travelling
+	spectators   map[chan *Level]bool
+	Log          *MessageLog
+	LogPath      string // where ExportLog writes the log to
+	recorder     *recorder
+	replay       *replay
+	ids          *idSource
 }
 
-func NewGame(numWindows int) *Game {
+// Options tells NewGame where to find the level maps and the world file.
+// Maps takes precedence over MapDir, with neither set the maps built into the binary are used.
+type Options struct {
+	Maps      fs.FS
+	MapDir    string
+	WorldFile string // name of the world file inside the map directory, "world" if empty
+	DefsFile  string // name of the monster and item definitions inside the map directory, "definitions.json" if empty
+	Generator Generator
+	Seed      int64 // seeds the dice for fights, monster wandering and magic, the same seed plays out the same
+	// StrictCorners forbids diagonal steps that squeeze past the corner of a wall
+	StrictCorners bool
+}
+
+// Generator builds the level depth below the down stair at from that doesn't lead anywhere yet. It returns
+// the new level and the position of its up stair, the portals between the two levels are wired up by the
+// game. When it fails the stair keeps leading nowhere.
+type Generator func(depth int, from LevelPos, defs *Definitions) (*Level, Pos, error)
+
+func (opts Options) maps() fs.FS {
+	if opts.Maps != nil {
+		return opts.Maps
+	}
+	if opts.MapDir != "" {
+		return os.DirFS(opts.MapDir)
+	}
+	return DefaultMaps()
+}
+
+func (opts Options) worldFile() string {
+	if opts.WorldFile != "" {
+		return opts.WorldFile
+	}
+	return "world"
+}
+
+// NewGame sets up a game with numWindows viewers, more can join once it runs, see Join
+func NewGame(numWindows int, opts Options) (*Game, error) {
+	defs, err := opts.Definitions()
+	if err != nil {
+		return nil, err
+	}
+	levels, err := loadLevels(opts.maps(), defs)
+	if err != nil {
+		return nil, err
+	}
+	start, err := loadWorldFile(opts.maps(), opts.worldFile(), levels)
+	if err != nil {
+		return nil, err
+	}
+	game := newGame(numWindows, opts, defs)
+	game.Levels = levels
+	game.CurrentLevel = start
+	game.Players = []*Player{start.Player}
+	game.attachLevels()
+	game.CurrentLevel.lineOfSight()
+	return game, nil
+}
+
+// newGame makes a game without any levels yet
+func newGame(numWindows int, opts Options, defs *Definitions) *Game {
 	levelChans := make([]chan *Level, numWindows)
 	for i := range levelChans {
-		levelChans[i] = make(chan *Level)
+		levelChans[i] = make(chan *Level, 1)
 	}
-	inputChan := make(chan *Input)
-	levels := loadLevels()
-	game := &Game{levelChans, inputChan, levels, nil}
-	game.loadWorldFile()
-	game.CurrentLevel.lineOfSight()
+	game := &Game{LevelChans: levelChans, InputChan: make(chan *Input), SavePath: "rpg.sav", LogPath: "rpg-log.txt", Defs: defs, options: opts}
+	game.dice = newDice(opts.Seed)
+	game.rng = rand.New(game.dice)
+	game.Log = &MessageLog{}
+	game.ids = &idSource{}
 	return game
 }
 
@@ -39,19 +116,36 @@ const (
 	Down
 	Left
 	Right
+	UpLeft
+	UpRight
+	DownLeft
+	DownRight
 	TakeAll
 	TakeItem
 	DropItem
 	EquipItem
 	QuitGame
 	CloseWindow
-	Search // temporary
+	Search // look for hidden traps and secret doors next to the player
+	SaveGame
+	LoadGame
+	Restart
+	UseItem
+	ExportLog
+	TravelTo // walk to Input.Pos over several turns
+	Explore
+	Travel // take the next step of a TravelTo or Explore, any other input interrupts them
+	JoinGame
+	Spectate
 )
 
 type Input struct {
 	Typ          InputType
-	Item         *Item
+	ItemID       EntityID // the item to take, drop, equip or use
+	Slot         Slot     // where to equip the item, AnySlot lets the game pick
+	Pos          Pos
 	LevelChannel chan *Level
+	Player       *Player // who is acting, nil for the player acting last
 }
 
 type Tile struct {
@@ -68,6 +162,8 @@ const (
 	OpenDoor        = '/'
 	UpStair         = 'u'
 	DownStair       = 'd'
+	Lever           = '&' // set by the level script, see Trigger
+	SecretDoor      = '+' // only in the map files, the door looks like a wall until it is found
 	Blank           = 0
 	Pending         = -1
 )
@@ -76,12 +172,17 @@ type Pos struct {
 	X, Y int
 }
 
+func (p Pos) String() string {
+	return strconv.Itoa(p.X) + "," + strconv.Itoa(p.Y)
+}
+
 type LevelPos struct {
 	*Level
 	Pos
 }
 
 type Entity struct {
+	ID EntityID // handed out by the game, see Game.adopt
 	Pos
 	Name string
 	Rune rune
@@ -90,6 +191,7 @@ type Entity struct {
 type Character struct {
 	Entity
 	Hitpoints    int
+	MaxHitpoints int
 	Strength     int
 	Speed        float64
 	ActionPoints float64
@@ -97,10 +199,26 @@ type Character struct {
 	Items        []*Item
 	Helmet       *Item
 	Weapon       *Item
+	Armor        *Item
+	Shield       *Item
+	Boots        *Item
+	LeftRing     *Item
+	RightRing    *Item
+	Amulet       *Item
+	// CounterAttack characters strike back at once when hit and still standing
+	CounterAttack bool
 }
 
 type Player struct {
 	Character
+	Turns    int
+	Kills    int
+	KilledBy string
+	XP       int
+	ExpLevel int // starts at 1, see XPForLevel
+	// Path holds the steps still to take towards a TravelTo target, next step first
+	Path      []Pos
+	Exploring bool // keeps picking new Paths to unexplored places until interrupted
 }
 
 type GameEvent int
@@ -114,338 +232,384 @@ const (
 	Portal
 	Pickup
 	Drop
+	GameOver
+	Use
 )
 
 type Level struct {
+	Name      string
 	Map       [][]Tile
-	Player    *Player
+	Player    *Player   // the player acting right now
+	Players   []*Player // everyone in the party
 	Monsters  map[Pos]*Monster
 	Items     map[Pos][]*Item
 	Portals   map[Pos]*LevelPos
-	Events    []string
-	EventPos  int
+	Log       *MessageLog // shared by all levels of a game
 	Debug     map[Pos]bool
 	LastEvent GameEvent
+	Depth     int              // how many generated levels lie above this one, 0 for the hand-drawn maps
+	Locks     map[Pos]*Lock    // locked doors, set up by the level script
+	Triggers  map[Pos]*Trigger // pressure plates and levers, set up by the level script
+	Traps     map[Pos]*Trap    // set up by the level script
+	Secrets   map[Pos]bool     // secret doors, true until somebody finds them
+
+	playerStart   *Pos
+	rng           *rand.Rand
+	terrain       map[rune]float64 // pathfinding cost of walking onto a tile, see TerrainDef
+	strictCorners bool
+	ids           *idSource // the game's, nil until the level is part of one
+	registry      registry
 }
 
-func (level *Level) DropItem(itemToDrop *Item, character *Character) {
+// DropItem puts the item with the given ID from the character's backpack on the ground
+func (level *Level) DropItem(id EntityID, character *Character) error {
 	pos := character.Pos
 	items := character.Items
 	for i, item := range items {
-		if item == itemToDrop {
+		if item.ID == id {
 			character.Items = append(character.Items[:i], character.Items[i+1:]...)
-			level.Items[pos] = append(level.Items[pos], item)
-			level.AddEvent(character.Name + " dropped: " + item.Name)
-			return
+			item.Pos = pos
+			level.putItem(item)
+			level.AddMessage(Loot, character.Name+" dropped: "+item.Name, character.ID, item.ID)
+			return nil
 		}
 	}
-	panic("tried to drop a remote item")
+	return &EntityError{id, "isn't in " + character.Name + "'s backpack"}
 }
 
-func (level *Level) MoveItem(itemToMove *Item, character *Character) {
-	fmt.Println("Moving item!")
+// MoveItem picks up the item with the given ID from under the character
+func (level *Level) MoveItem(id EntityID, character *Character) error {
 	pos := character.Pos
 	items := level.Items[pos]
 	for i, item := range items {
-		if item == itemToMove {
+		if item.ID == id {
 			items = append(items[:i], items[i+1:]...)
 			level.Items[pos] = items
+			delete(level.registry.items, item.ID)
+			level.AddMessage(Loot, character.Name+" picked up: "+item.Name, character.ID, item.ID)
+			if stack := findStack(character.Items, item); stack != nil {
+				stack.Count += item.Count
+				return nil
+			}
 			character.Items = append(character.Items, item)
-			level.AddEvent(character.Name + " picked up: " + item.Name)
-			return
+			return nil
 		}
 	}
-	panic("tried to move a remote item")
+	return &EntityError{id, "isn't on the ground under " + character.Name}
 }
 
-func (level *Level) Attack(c1, c2 *Character) {
-	c1.ActionPoints--
-	c1AttackPower := c1.Strength
-	if c1.Weapon != nil {
-		c1AttackPower = int(float64(c1.Strength) * c1.Weapon.Power)
-	}
-	damage := c1AttackPower
-	if c2.Helmet != nil {
-		damage = int(float64(damage) * (1.0 - c2.Helmet.Power))
+// findStack looks for a consumable in items that item can be stacked onto
+func findStack(items []*Item, item *Item) *Item {
+	if item.Typ != Consumable {
+		return nil
 	}
-
-	c2.Hitpoints -= damage
-	if c2.Hitpoints > 0 {
-		level.AddEvent(c1.Name + " Attacked " + c2.Name + " for " + strconv.Itoa(damage))
-		c2.ActionPoints -= 1
-		c1.Hitpoints -= c2.Strength
-	} else {
-		level.AddEvent(c1.Name + " Killed " + c2.Name)
+	for _, other := range items {
+		if other.Typ == Consumable && other.Name == item.Name && other.Effect == item.Effect {
+			return other
+		}
 	}
+	return nil
 }
 
+// AddEvent logs a message that doesn't fit any of the other kinds
 func (level *Level) AddEvent(event string) {
-	level.Events[level.EventPos] = event
-
-	level.EventPos++
-	if level.EventPos == len(level.Events) {
-		level.EventPos = 0
-	}
+	level.AddMessage(General, event)
 }
 
+// lineOfSight marks what the player can see right now and remembers it as seen
 func (level *Level) lineOfSight() {
-	pos := level.Player.Pos
-	dist := level.Player.SightRange
-
-	for y := pos.Y - dist; y <= pos.Y+dist; y++ {
-		for x := pos.X - dist; x <= pos.X+dist; x++ {
-			xDelta := pos.X - x
-			yDelta := pos.Y - y
-			d := math.Sqrt(float64(xDelta*xDelta + yDelta*yDelta))
-			if d <= float64(dist) { // if in line of sight (within the LoS circle)
-				level.bresenham(pos, Pos{x, y})
-			}
+	for y, row := range level.Map {
+		for x := range row {
+			level.Map[y][x].Visible = false
+		}
+	}
+	// the party shares what it sees
+	for _, p := range level.party() {
+		if p.Hitpoints <= 0 && p != level.Player {
+			continue
 		}
+		level.FieldOfView(p.Pos, p.Stats().SightRange, func(pos Pos) {
+			level.Map[pos.Y][pos.X].Visible = true
+			level.Map[pos.Y][pos.X].Seen = true
+		})
 	}
 }
 
-func (level *Level) bresenham(start Pos, end Pos) {
-	steep := math.Abs(float64(end.Y-start.Y)) > math.Abs(float64(end.X-start.X)) // whether the line skews toward y
-	if steep {
-		start.X, start.Y = start.Y, start.X
-		end.X, end.Y = end.Y, end.X
-		//temp := start.X
-		//start.X = start.Y
-		//start.Y = temp
-
-		//temp = end.X
-		//end.X = end.Y
-		//end.Y = temp
+func loadWorldFile(maps fs.FS, worldFile string, levels map[string]*Level) (*Level, error) {
+	file, err := maps.Open(worldFile)
+	if err != nil {
+		return nil, err
 	}
+	defer file.Close()
 
-	deltaY := int(math.Abs(float64(end.Y - start.Y)))
-	err := 0
-	y := start.Y
-	ystep := 1
-	if start.Y >= end.Y {
-		ystep = -1
+	start, err := LoadWorld(file, levels)
+	var worldErr *WorldError
+	if errors.As(err, &worldErr) {
+		worldErr.File = worldFile
 	}
+	return start, err
+}
 
-	if start.X > end.X {
-		deltaX := start.X - end.X
-
-		for x := start.X; x > end.X; x-- {
-			var pos Pos
-			if steep {
-				pos = Pos{y, x}
-			} else {
-				pos = Pos{x, y}
-			}
-
-			level.Map[pos.Y][pos.X].Visible = true
-			level.Map[pos.Y][pos.X].Seen = true
+// LoadWorld reads the world file, whose first row names the starting level and every
+// following row a portal: level,x,y, level to teleport to,x,y. It returns the starting level.
+func LoadWorld(r io.Reader, levels map[string]*Level) (*Level, error) {
+	startRow, portals, err := readWorld(r)
+	if err != nil {
+		return nil, err
+	}
 
-			if !canSeeThrough(level, pos) {
-				return
-			}
+	start := levels[startRow.name]
+	if start == nil {
+		return nil, &WorldError{Line: startRow.line, Field: 1, Value: startRow.name, Msg: "unknown level"}
+	}
 
-			err += deltaY
-			if 2*err >= deltaX {
-				y += ystep
-				err -= deltaX
-			}
+	for _, portal := range portals {
+		levelWithPortal := levels[portal.from]
+		if levelWithPortal == nil {
+			return nil, &WorldError{Line: portal.line, Field: 1, Value: portal.from, Msg: "unknown level"}
 		}
-	} else {
-		deltaX := end.X - start.X
-
-		for x := start.X; x < end.X; x++ {
-			var pos Pos
-			if steep {
-				pos = Pos{y, x}
-			} else {
-				pos = Pos{x, y}
-			}
-
-			level.Map[pos.Y][pos.X].Visible = true
-			level.Map[pos.Y][pos.X].Seen = true
-
-			if !canSeeThrough(level, pos) {
-				return
-			}
-
-			err += deltaY
-			if 2*err >= deltaX {
-				y += ystep
-				err -= deltaX
-			}
+		if !inRange(levelWithPortal, portal.fromPos) {
+			return nil, &WorldError{Line: portal.line, Field: 2, Value: portal.fromPos.String(), Msg: "portal outside of level " + portal.from}
+		}
+		levelToTeleportTo := levels[portal.to]
+		if levelToTeleportTo == nil {
+			return nil, &WorldError{Line: portal.line, Field: 4, Value: portal.to, Msg: "unknown level"}
+		}
+		if !inRange(levelToTeleportTo, portal.toPos) {
+			return nil, &WorldError{Line: portal.line, Field: 5, Value: portal.toPos.String(), Msg: "portal leads outside of level " + portal.to}
 		}
+		levelWithPortal.Portals[portal.fromPos] = &LevelPos{levelToTeleportTo, portal.toPos}
 	}
+	return start, nil
 }
 
-func (game *Game) loadWorldFile() {
-	file, err := os.Open("rpg/game/maps/world")
-	if err != nil {
-		panic(err)
-	}
-	csvReader := csv.NewReader(file)
-	csvReader.FieldsPerRecord = -1 // allows varying no of cells in rows
-	csvReader.TrimLeadingSpace = true
-	rows, err := csvReader.ReadAll()
-	if err != nil {
-		panic(err)
-	}
+type worldRow struct {
+	line    int
+	name    string
+	from    string
+	fromPos Pos
+	to      string
+	toPos   Pos
+}
 
-	for rowIndex, row := range rows {
-		if rowIndex == 0 {
-			game.CurrentLevel = game.Levels[row[0]]
-			if game.CurrentLevel == nil {
-				fmt.Println("couldn't find level name in world file")
-				panic(nil)
-			}
+// readWorld only checks the syntax of the world file, the level names are resolved by the caller
+func readWorld(r io.Reader) (worldRow, []worldRow, error) {
+	var start worldRow
+	var portals []worldRow
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		if strings.TrimSpace(scanner.Text()) == "" {
 			continue
 		}
-		x, err := strconv.ParseInt(row[1], 10, 64)
+		csvReader := csv.NewReader(strings.NewReader(scanner.Text()))
+		csvReader.FieldsPerRecord = -1 // allows varying no of cells in rows
+		csvReader.TrimLeadingSpace = true
+		row, err := csvReader.Read()
 		if err != nil {
-			panic(err)
+			return start, nil, &WorldError{Line: lineNo, Msg: err.Error()}
 		}
-		y, err := strconv.ParseInt(row[2], 10, 64)
-		if err != nil {
-			panic(err)
+		for i := range row {
+			row[i] = strings.TrimSpace(row[i])
 		}
-		pos := Pos{int(x), int(y)}
 
-		levelWithPortal := game.Levels[row[0]]
-		if levelWithPortal == nil {
-			fmt.Println("couldn't find level name in world file")
-			panic(nil)
+		if start.line == 0 {
+			if len(row) != 1 {
+				return start, nil, &WorldError{Line: lineNo, Msg: "first row must only name the starting level"}
+			}
+			start = worldRow{line: lineNo, name: row[0]}
+			continue
 		}
 
-		x, err = strconv.ParseInt(row[4], 10, 64)
-		if err != nil {
-			panic(err)
-		}
-		y, err = strconv.ParseInt(row[5], 10, 64)
-		if err != nil {
-			panic(err)
+		if len(row) != 6 {
+			return start, nil, &WorldError{Line: lineNo, Msg: fmt.Sprintf("portal row needs 6 fields, has %d", len(row))}
 		}
-		posToTeleportTo := Pos{int(x), int(y)}
-
-		levelToTeleportTo := game.Levels[row[3]]
-		if levelToTeleportTo == nil {
-			fmt.Println("couldn't find level name in world file")
-			panic(nil)
+		coords := make([]int, 0, 4)
+		for _, field := range []int{1, 2, 4, 5} {
+			n, err := strconv.Atoi(row[field])
+			if err != nil {
+				return start, nil, &WorldError{Line: lineNo, Field: field + 1, Value: row[field], Msg: "not a number"}
+			}
+			coords = append(coords, n)
 		}
-		levelWithPortal.Portals[pos] = &LevelPos{levelToTeleportTo, posToTeleportTo}
+		portals = append(portals, worldRow{
+			line:    lineNo,
+			from:    row[0],
+			fromPos: Pos{coords[0], coords[1]},
+			to:      row[3],
+			toPos:   Pos{coords[2], coords[3]},
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return start, nil, err
+	}
+	if start.line == 0 {
+		return start, nil, &WorldError{Msg: "no starting level"}
 	}
+	return start, portals, nil
 }
 
-// TODO take in path
-func loadLevels() map[string]*Level {
-	// Player init
+func newPlayer() *Player {
 	player := &Player{}
 	player.Strength = 20
 	player.Hitpoints = 50
-	player.ActionPoints = 0
+	player.MaxHitpoints = 50
+	player.ActionPoints = actionReady // the player gets the first move
 	player.Name = "GoMan"
 	player.Rune = '@'
 	player.Speed = 1.0
 	player.SightRange = 7
+	player.ExpLevel = 1
+	return player
+}
+
+// loadLevels loads every *.map file, the player starts on the one level with an '@' and is shared by all of them
+func loadLevels(maps fs.FS, defs *Definitions) (map[string]*Level, error) {
+	player := newPlayer()
 
 	levels := make(map[string]*Level)
 
-	// exemplary file path "rpg/game/maps/level1.map"
-	filenames, err := filepath.Glob("rpg/game/maps/*.map")
+	// exemplary file name "level1.map"
+	filenames, err := fs.Glob(maps, "*.map")
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
+	startFound := false
 	for _, filename := range filenames {
-		extIndex := strings.LastIndex(filename, ".map")
-		lastSlashIndex := strings.LastIndex(filename, "/")
-		levelName := filename[lastSlashIndex+1 : extIndex]
-		fmt.Println("level name:", levelName)
-		file, err := os.Open(filename)
+		levelName := strings.TrimSuffix(path.Base(filename), ".map")
+		file, err := maps.Open(filename)
+		if err != nil {
+			return nil, err
+		}
+		level, err := LoadLevel(levelName, file, defs)
+		file.Close()
 		if err != nil {
-			panic(err)
-		}
-		defer file.Close()
-		scanner := bufio.NewScanner(file)
-		levelLines := make([]string, 0)
-		longestRow := 0
-		index := 0
-		for scanner.Scan() {
-			levelLines = append(levelLines, scanner.Text())
-			if len(levelLines[index]) > longestRow {
-				longestRow = len(levelLines[index])
+			return nil, err
+		}
+		if errs := loadScript(maps, level, defs); len(errs) > 0 {
+			return nil, errs[0]
+		}
+		if level.playerStart != nil {
+			if startFound {
+				return nil, &MapError{File: filename, Line: level.playerStart.Y + 1, Column: level.playerStart.X + 1,
+					Rune: '@', Msg: "second player start"}
 			}
-			index++
+			startFound = true
+			player.Pos = *level.playerStart
 		}
-		level := &Level{}
-		level.Debug = make(map[Pos]bool)
-		level.Events = make([]string, 10)
-		level.EventPos = 0
 		level.Player = player
-		level.Map = make([][]Tile, len(levelLines))
-		level.Monsters = make(map[Pos]*Monster)
-		level.Portals = make(map[Pos]*LevelPos)
-		level.Items = make(map[Pos][]*Item)
-
-		for i := range level.Map {
-			level.Map[i] = make([]Tile, longestRow)
-		}
-
-		for y := 0; y < len(level.Map); y++ {
-			line := levelLines[y]
-			for x, c := range line {
-				var t Tile
-				t.OverlayRune = Blank
-				pos := Pos{x, y}
-				switch c {
-				case ' ', '\t', '\n', '\r':
-					t.Rune = Blank
-				case '#':
-					t.Rune = StoneWall
-				case '|':
-					t.OverlayRune = ClosedDoor
-					t.Rune = Pending
-				case '/':
-					t.OverlayRune = OpenDoor
-					t.Rune = Pending
-				case 'u':
-					t.OverlayRune = UpStair
-					t.Rune = Pending
-				case 'd':
-					t.OverlayRune = DownStair
-					t.Rune = Pending
-				case '.':
-					t.Rune = DirtFloor
-				case '@':
-					level.Player.X = x
-					level.Player.Y = y
-					t.Rune = Pending
-				case 'R':
-					level.Monsters[pos] = NewRat(pos)
-					t.Rune = Pending
-				case 'S':
-					level.Monsters[pos] = NewSpider(pos)
-					t.Rune = Pending
-				case 's':
-					level.Items[pos] = append(level.Items[pos], NewSword(pos))
-					t.Rune = Pending
-				case 'h':
-					level.Items[pos] = append(level.Items[pos], NewHelmet(pos))
-					t.Rune = Pending
-				default:
-					panic("Invalid character in map!")
+		levels[levelName] = level
+	}
+	if !startFound {
+		return nil, errors.New("no level has a player start '@'")
+	}
+	return levels, nil
+}
+
+// NewLevel makes an empty level of blank tiles for loaders and generators to fill in
+func NewLevel(width, height int, player *Player) *Level {
+	level := &Level{}
+	level.Debug = make(map[Pos]bool)
+	level.Log = &MessageLog{}
+	level.Player = player
+	level.Map = make([][]Tile, height)
+	level.Monsters = make(map[Pos]*Monster)
+	level.Portals = make(map[Pos]*LevelPos)
+	level.Items = make(map[Pos][]*Item)
+	level.Secrets = make(map[Pos]bool)
+
+	for i := range level.Map {
+		level.Map[i] = make([]Tile, width)
+	}
+	return level
+}
+
+// LoadLevel reads one map, the level gets a player of its own standing on the '@' if there is one.
+// Monsters and items are placed by looking their runes up in defs.
+func LoadLevel(name string, r io.Reader, defs *Definitions) (*Level, error) {
+	filename := name + ".map"
+	scanner := bufio.NewScanner(r)
+	levelLines := make([]string, 0)
+	longestRow := 0
+	index := 0
+	for scanner.Scan() {
+		levelLines = append(levelLines, scanner.Text())
+		if width := utf8.RuneCountInString(levelLines[index]); width > longestRow {
+			longestRow = width
+		}
+		index++
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if longestRow == 0 {
+		return nil, &MapError{File: filename, Msg: "empty map"}
+	}
+
+	level := NewLevel(longestRow, len(levelLines), newPlayer())
+	level.Name = name
+
+	for y := 0; y < len(level.Map); y++ {
+		line := levelLines[y]
+		column := 0
+		for _, c := range line {
+			x := column // range hands out byte offsets, the map is laid out in runes
+			column++
+			var t Tile
+			t.OverlayRune = Blank
+			pos := Pos{x, y}
+			switch c {
+			case ' ', '\t', '\n', '\r':
+				t.Rune = Blank
+			case '#':
+				t.Rune = StoneWall
+			case '+':
+				t.Rune = StoneWall
+				level.Secrets[pos] = true
+			case '|':
+				t.OverlayRune = ClosedDoor
+				t.Rune = Pending
+			case '/':
+				t.OverlayRune = OpenDoor
+				t.Rune = Pending
+			case 'u':
+				t.OverlayRune = UpStair
+				t.Rune = Pending
+			case 'd':
+				t.OverlayRune = DownStair
+				t.Rune = Pending
+			case '.':
+				t.Rune = DirtFloor
+			case '@':
+				if level.playerStart != nil {
+					return nil, &MapError{File: filename, Line: y + 1, Column: column, Rune: c, Msg: "second player start"}
 				}
-				level.Map[y][x] = t
+				level.playerStart = &Pos{x, y}
+				level.Player.Pos = pos
+				t.Rune = Pending
+			default:
+				if monster := defs.MonsterByRune(c); monster != nil {
+					level.AddMonster(monster.New(pos, level.random()))
+				} else if item := defs.ItemByRune(c); item != nil {
+					level.AddItem(item.New(pos))
+				} else {
+					return nil, &MapError{File: filename, Line: y + 1, Column: column, Rune: c, Msg: "invalid character"}
+				}
+				t.Rune = Pending
 			}
+			level.Map[y][x] = t
 		}
+	}
 
-		for y, row := range level.Map {
-			for x, tile := range row {
-				if tile.Rune == Pending {
-					level.Map[y][x].Rune = level.bfsFloor(Pos{x, y})
-				}
+	for y, row := range level.Map {
+		for x, tile := range row {
+			if tile.Rune == Pending {
+				level.Map[y][x].Rune = level.bfsFloor(Pos{x, y})
 			}
 		}
-		levels[levelName] = level
 	}
-	return levels
+	return level, nil
 }
 
 func inRange(level *Level, pos Pos) bool {
@@ -460,7 +624,7 @@ func canWalk(level *Level, pos Pos) bool {
 			return false
 		}
 		switch t.OverlayRune {
-		case ClosedDoor:
+		case ClosedDoor, Lever:
 			return false
 		}
 		_, exists := level.Monsters[pos]
@@ -489,135 +653,178 @@ func canSeeThrough(level *Level, pos Pos) bool {
 	return false
 }
 
-func checkDoor(level *Level, pos Pos) {
+func checkDoor(level *Level, pos Pos) bool {
 	t := level.Map[pos.Y][pos.X]
 	if t.OverlayRune == ClosedDoor {
+		if !level.unlock(pos) {
+			return false
+		}
 		level.Map[pos.Y][pos.X].OverlayRune = OpenDoor
 		level.LastEvent = DoorOpen
+		level.AddMessage(Door, level.Player.Name+" opened a door")
 		level.lineOfSight()
+		return true
+	}
+	return false
+}
+
+// generateBelow makes a new level with the game's generator and connects it to the down stair at pos,
+// nil if the generator failed
+func (game *Game) generateBelow(level *Level, pos Pos) *LevelPos {
+	depth := level.Depth + 1
+	newLevel, upStair, err := game.options.Generator(depth, LevelPos{level, pos}, game.Defs)
+	if err != nil {
+		level.AddMessage(System, "The stairs lead nowhere: "+err.Error())
+		return nil
+	}
+	newLevel.Depth = depth
+
+	name := "depth" + strconv.Itoa(depth)
+	for i := 2; game.Levels[name] != nil; i++ {
+		name = "depth" + strconv.Itoa(depth) + "-" + strconv.Itoa(i)
 	}
+	game.Levels[name] = newLevel
+	newLevel.Name = name
+	game.attachLevel(newLevel)
+	game.adopt()
+
+	level.Portals[pos] = &LevelPos{newLevel, upStair}
+	newLevel.Portals[upStair] = &LevelPos{level, pos}
+	return level.Portals[pos]
 }
 
 func (game *Game) Move(to Pos) {
 	level := game.CurrentLevel
 	portal := level.Portals[to]
+	if portal == nil && game.options.Generator != nil && level.Map[to.Y][to.X].OverlayRune == DownStair {
+		portal = game.generateBelow(level, to)
+	}
 	if portal != nil {
+		player := level.Player
 		game.CurrentLevel = portal.Level
-		game.CurrentLevel.Player.Pos = portal.Pos
+		player.Pos = portal.Pos
+		game.gatherParty(player)
+		game.CurrentLevel.AddMessage(General, player.Name+" entered "+portal.Level.Name)
 		game.CurrentLevel.lineOfSight()
 	} else {
 		level.Player.Pos = to
 		level.LastEvent = Move
-		for y, row := range level.Map {
-			for x := range row {
-				level.Map[y][x].Visible = false
-			}
-		}
 		level.lineOfSight()
+		game.stepOn(to)
 	}
 }
 
-func (game *Game) resolveMovement(pos Pos) {
+// resolveMovement attacks, walks or opens a door depending on what is at pos and returns the action's cost
+func (game *Game) resolveMovement(pos Pos) float64 {
 	level := game.CurrentLevel
 	monster, exists := level.Monsters[pos]
+	if other := level.PlayerAt(pos); other != nil && other != level.Player {
+		return 0 // the party doesn't fight among itself
+	}
 	if exists {
 		level.Attack(&level.Player.Character, &monster.Character)
 		level.LastEvent = Attack
 		if monster.Hitpoints <= 0 {
 			monster.Kill(level)
+		} else {
+			monster.Disturb(level)
 		}
 		if level.Player.Hitpoints <= 0 {
-			panic("YOU DIED")
+			level.Player.KilledBy = monster.Name
 		}
+		return AttackCost
+	} else if cost, pulled := game.pull(pos); pulled {
+		return cost
 	} else if canWalk(level, pos) {
 		game.Move(pos)
-	} else {
-		checkDoor(level, pos)
+		return MoveCost
+	} else if checkDoor(level, pos) {
+		return DoorCost
 	}
+	return 0 // bumping into a wall doesn't take any time
 }
 
-func equip(c *Character, itemToEquip *Item) {
-	for i, item := range c.Items {
-		if item == itemToEquip {
-			c.Items = append(c.Items[:i],c.Items[i+1:]...)
-			if itemToEquip.Typ == Helmet {
-				c.Helmet = itemToEquip
-			} else if itemToEquip.Typ == Weapon {
-				c.Weapon = itemToEquip
-			}
-			return
+// handleInput carries out the player's input and returns how many action points it cost,
+// inputs that don't take any game time cost 0
+func (game *Game) handleInput(input *Input) float64 {
+	if game.Dead {
+		switch input.Typ {
+		case Restart, LoadGame, CloseWindow, JoinGame, Spectate:
+		default:
+			return 0 // the dead don't walk
 		}
 	}
-	panic("someone tried to equip a thing they don't have")
-}
 
-func (game *Game) handleInput(input *Input) {
 	level := game.CurrentLevel
 	p := level.Player
+	if input.Typ != Travel {
+		game.stopTravel()
+	}
 	switch input.Typ {
-	case Up:
-		newPos := Pos{p.X, p.Y - 1}
-		game.resolveMovement(newPos)
-	case Down:
-		newPos := Pos{p.X, p.Y + 1}
-		game.resolveMovement(newPos)
-	case Left:
-		newPos := Pos{p.X - 1, p.Y}
-		game.resolveMovement(newPos)
-	case Right:
-		newPos := Pos{p.X + 1, p.Y}
-		game.resolveMovement(newPos)
+	case Up, Down, Left, Right, UpLeft, UpRight, DownLeft, DownRight:
+		newPos := p.Pos.Add(directions[input.Typ])
+		if !level.canStep(p.Pos, newPos) {
+			return 0
+		}
+		return game.resolveMovement(newPos)
 	case TakeAll:
-		for _, item := range level.Items[p.Pos] {
-			level.MoveItem(item, &p.Character)
+		items := level.Items[p.Pos]
+		if len(items) == 0 {
+			return 0
+		}
+		for _, item := range append([]*Item(nil), items...) {
+			level.MoveItem(item.ID, &p.Character)
 		}
 		level.LastEvent = Pickup
+		return PickupCost
 	case TakeItem:
-		level.MoveItem(input.Item, &level.Player.Character)
+		if err := level.MoveItem(input.ItemID, &p.Character); err != nil {
+			return game.refuse(err)
+		}
 		level.LastEvent = Pickup
+		return PickupCost
 	case EquipItem:
-		equip(&level.Player.Character, input.Item)
-	//case Search:
-	//	//bfs(ui, Level, Level.Player.Pos)
-	//	level.astar(level.Player.Pos, Pos{3, 2})
+		if err := equip(&p.Character, input.ItemID, input.Slot); err != nil {
+			return game.refuse(err)
+		}
+		return EquipCost
+	case Search:
+		return game.search()
 	case DropItem:
-		level.DropItem(input.Item, &level.Player.Character)
+		if err := level.DropItem(input.ItemID, &p.Character); err != nil {
+			return game.refuse(err)
+		}
 		level.LastEvent = Drop
-	case CloseWindow:
-		// FIXME leads to error on quit, every time
-		close(input.LevelChannel)
-		chanIndex := 0
-		for i, c := range game.LevelChans {
-			if c == input.LevelChannel {
-				chanIndex = i
-				break
-			}
+		return DropCost
+	case UseItem:
+		if err := level.UseItem(input.ItemID, &p.Character); err != nil {
+			return game.refuse(err)
 		}
-		//removing specific item from the existing slice
-		game.LevelChans = append(game.LevelChans[:chanIndex], game.LevelChans[chanIndex+1:]...) // ... turns each following item into an argument
-	}
-}
-
-func getNeighbors(level *Level, pos Pos) []Pos {
-	neighbors := make([]Pos, 0, 4)
-	left := Pos{pos.X - 1, pos.Y}
-	right := Pos{pos.X + 1, pos.Y}
-	up := Pos{pos.X, pos.Y - 1}
-	down := Pos{pos.X, pos.Y + 1}
-
-	if canWalk(level, right) {
-		neighbors = append(neighbors, right)
-	}
-	if canWalk(level, left) {
-		neighbors = append(neighbors, left)
-	}
-	if canWalk(level, up) {
-		neighbors = append(neighbors, up)
-	}
-	if canWalk(level, down) {
-		neighbors = append(neighbors, down)
+		level.lineOfSight()
+		level.LastEvent = Use
+		return UseCost
+	case SaveGame:
+		game.saveToFile()
+	case LoadGame:
+		game.loadFromFile()
+	case Restart:
+		game.restart()
+	case ExportLog:
+		game.exportLog()
+	case TravelTo:
+		return game.startTravel(input.Pos)
+	case Explore:
+		p.Exploring = true
+		game.spotted = level.visibleMonsters()
+		return game.travelStep()
+	case Travel:
+		return game.travelStep()
+	case CloseWindow:
+		game.removeViewer(input.LevelChannel)
+	case JoinGame, Spectate:
+		game.addViewer(input.LevelChannel, input.Typ == Spectate)
 	}
-	return neighbors
+	return 0
 }
 
 // Breadth-first Search
@@ -649,11 +856,16 @@ func (level *Level) bfsFloor(start Pos) rune {
 }
 
 func (level *Level) astar(start Pos, goal Pos) []Pos {
+	return level.astarWith(start, goal, getNeighbors)
+}
+
+// astarWith finds the cheapest path from start to goal, both included, stepping to the tiles neighbors returns
+func (level *Level) astarWith(start Pos, goal Pos, neighbors func(*Level, Pos) []Pos) []Pos {
 	frontier := make(pqueue, 0, 8)
 	frontier = frontier.push(start, 1)
 	cameFrom := make(map[Pos]Pos)
 	cameFrom[start] = start
-	costSoFar := make(map[Pos]int)
+	costSoFar := make(map[Pos]float64)
 	costSoFar[start] = 0
 
 	var current Pos
@@ -675,14 +887,12 @@ func (level *Level) astar(start Pos, goal Pos) []Pos {
 			return path
 		}
 
-		for _, next := range getNeighbors(level, current) {
-			newCost := costSoFar[current] + 1 // always 1 for now
+		for _, next := range neighbors(level, current) {
+			newCost := costSoFar[current] + level.stepCost(current, next)
 			_, exists := costSoFar[next]
 			if !exists || newCost < costSoFar[next] {
 				costSoFar[next] = newCost
-				xDist := int(math.Abs(float64(goal.X - next.X)))
-				yDist := int(math.Abs(float64(goal.Y - next.Y)))
-				priority := newCost + xDist + yDist
+				priority := newCost + octile(next, goal)
 				frontier = frontier.push(next, priority)
 				cameFrom[next] = current
 			}
@@ -691,18 +901,120 @@ func (level *Level) astar(start Pos, goal Pos) []Pos {
 	return nil
 }
 
-func (game *Game) Run() {
-	fmt.Println("Starting ...")
+func (game *Game) gameOver() {
+	level := game.CurrentLevel
+	game.Dead = true
+	level.LastEvent = GameOver
+	game.stopTravel()
+	if level.Player.KilledBy != "" {
+		level.AddEvent(level.Player.Name + " was killed by " + level.Player.KilledBy)
+	} else {
+		level.AddEvent(level.Player.Name + " died")
+	}
+}
 
-	count := 0
-	for _, lchan := range game.LevelChans {
-		lchan <- game.CurrentLevel
+// restart throws away the current run and reloads every level from disk
+func (game *Game) restart() {
+	levels, err := loadLevels(game.options.maps(), game.Defs)
+	if err != nil {
+		game.CurrentLevel.AddMessage(System, "Restart failed: "+err.Error())
+		return
+	}
+	start, err := loadWorldFile(game.options.maps(), game.options.worldFile(), levels)
+	if err != nil {
+		game.CurrentLevel.AddMessage(System, "Restart failed: "+err.Error())
+		return
+	}
+	game.Levels = levels
+	game.CurrentLevel = start
+	game.Players = []*Player{start.Player}
+	game.Dead = false
+	game.ids = &idSource{}
+	game.attachLevels()
+	game.CurrentLevel.lineOfSight()
+	game.CurrentLevel.AddMessage(System, "New game")
+}
+
+// attachLevels hands the game's dice, message log and rules to every level, sharing the dice
+// makes the whole game play out the same for the same seed
+func (game *Game) attachLevels() {
+	for _, level := range game.Levels {
+		game.attachLevel(level)
+	}
+	game.adopt()
+}
+
+func (game *Game) attachLevel(level *Level) {
+	level.rng = game.rng
+	level.Log = game.Log
+	level.Players = game.Players
+	level.terrain = game.Defs.terrainCosts
+	level.strictCorners = game.options.StrictCorners
+}
+
+// Handle carries out one input and lets time pass until the acting player is due again.
+// Run calls it for every input, a server that drives the game from its own loop calls it directly.
+func (game *Game) Handle(input *Input) {
+	if input.Player != nil {
+		if !game.InParty(input.Player) {
+			return // dead or gone
+		}
+		game.setActing(input.Player)
+	}
+	var entry RecordedInput
+	recording := game.recorder != nil && recordable(input.Typ)
+	if recording {
+		p := game.CurrentLevel.Player
+		entry = RecordedInput{Turn: p.Turns, Player: game.playerIndex(p), Typ: input.Typ, Pos: input.Pos, Item: input.ItemID, Slot: input.Slot}
 	}
+	cost := game.handleInput(input)
+	if cost > 0 && !game.Dead {
+		player := game.CurrentLevel.Player
+		player.ActionPoints -= cost
+		player.Turns++
+		game.advanceTime()
+	}
+	game.checkDeaths()
+	if recording {
+		game.record(entry)
+	}
+}
+
+func (game *Game) Run() {
+	count := 0
+	defer game.closeViewers() // lets the viewers still around know the game is over
+	game.broadcast()
 
 	// GAME LOOP
-	for input := range game.InputChan { // getting mult inputs via one channel
-		//fmt.Println("Got Input:", input)
+	for {
+		var input *Input
+		select {
+		case in, ok := <-game.InputChan: // getting mult inputs via one channel
+			if !ok {
+				return
+			}
+			input = in
+		case <-game.replayTick():
+			game.replayStep()
+			game.broadcast()
+			continue
+		}
 		if input != nil {
+			if game.replay != nil && recordable(input.Typ) && !game.spectators[input.LevelChannel] {
+				if input.Typ == Travel {
+					continue // the windows keep journeys going, the recorded steps already do that
+				}
+				game.stopReplay("Replay stopped, you have the controls")
+			}
+			if game.spectators[input.LevelChannel] {
+				switch input.Typ {
+				case CloseWindow:
+				case QuitGame:
+					input.Typ = CloseWindow // a spectator quitting only takes itself out of the game
+				default:
+					continue
+				}
+			}
 			if input.Typ == QuitGame {
 				return
 			}
@@ -713,22 +1025,15 @@ func (game *Game) Run() {
 			//	game.Level.Debug[pos] = true
 			//}
 
-			game.handleInput(input)
+			game.Handle(input)
 
 			//game.Level.AddEvent("Move:" + strconv.Itoa(count))
 			count++
 
-			for _, monster := range game.CurrentLevel.Monsters {
-				monster.Update(game.CurrentLevel)
-			}
-
-			if len(game.LevelChans) == 0 {
-				return
-			}
-
-			for _, lchan := range game.LevelChans {
-				lchan <- game.CurrentLevel
+			if len(game.LevelChans) == len(game.spectators) {
+				return // no player left to move, the spectators are told by closeViewers
 			}
+			game.broadcast()
 		}
 	}
 }
@@ -0,0 +1,350 @@
+// Package gen builds random levels out of the same tiles, monsters and items the hand-drawn maps use.
+package gen
+
+import (
+	"fmt"
+	"gameswithgo/rpg/game"
+	"hash/fnv"
+	"math/rand"
+)
+
+type Style int
+
+const (
+	RoomsAndCorridors Style = iota
+	Cave
+)
+
+type Config struct {
+	Width, Height int
+	Style         Style
+	Monsters      int // monsters on the first generated level, one more for every level below
+	Items         int
+}
+
+func DefaultConfig() Config {
+	return Config{Width: 60, Height: 30, Style: RoomsAndCorridors, Monsters: 3, Items: 2}
+}
+
+// New returns a game.Generator that alternates between rooms and caves on every other level.
+// Each level gets its own rand source derived from seed and the stair leading down to it, so a
+// level looks the same no matter in which order the levels are generated, and two down stairs
+// of one level lead to different levels.
+func New(seed int64) game.Generator {
+	return func(depth int, from game.LevelPos, defs *game.Definitions) (*game.Level, game.Pos, error) {
+		cfg := DefaultConfig()
+		if depth%2 == 0 {
+			cfg.Style = Cave
+		}
+		return Generate(stairSeed(seed, from), depth, cfg, from.Player, defs)
+	}
+}
+
+// stairSeed mixes the level and position of a stair into seed
+func stairSeed(seed int64, from game.LevelPos) int64 {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d %s %d %d", seed, from.Name, from.X, from.Y)
+	return int64(h.Sum64())
+}
+
+// Generate builds a single level with an up stair, a down stair, monsters and items out of defs and returns it
+// together with the position of its up stair. It fails if the map is too small for two stairs.
+func Generate(seed int64, depth int, cfg Config, player *game.Player, defs *game.Definitions) (*game.Level, game.Pos, error) {
+	if cfg.Width < minSize || cfg.Height < minSize {
+		return nil, game.Pos{}, fmt.Errorf("a %dx%d map is too small for a level", cfg.Width, cfg.Height)
+	}
+	r := rand.New(rand.NewSource(seed*1000003 + int64(depth)))
+	level := game.NewLevel(cfg.Width, cfg.Height, player)
+
+	switch cfg.Style {
+	case Cave:
+		carveCave(level, r)
+	default:
+		carveRooms(level, r)
+	}
+
+	floor := floorTiles(level)
+	if len(floor) < 2 {
+		// the noise closed the cave up, fall back to rooms rather than leaving the player stuck
+		level = game.NewLevel(cfg.Width, cfg.Height, player)
+		carveRooms(level, r)
+		floor = floorTiles(level)
+	}
+	if len(floor) < 2 {
+		return nil, game.Pos{}, fmt.Errorf("no room for two stairs on a %dx%d map", cfg.Width, cfg.Height)
+	}
+	addWalls(level)
+	r.Shuffle(len(floor), func(i, j int) { floor[i], floor[j] = floor[j], floor[i] })
+
+	upStair := floor[0]
+	downStair := farthest(upStair, floor[1:])
+	level.Map[upStair.Y][upStair.X].OverlayRune = game.UpStair
+	level.Map[downStair.Y][downStair.X].OverlayRune = game.DownStair
+
+	free := make([]game.Pos, 0, len(floor))
+	for _, pos := range floor {
+		if pos != upStair && pos != downStair && manhattan(pos, upStair) > 3 {
+			free = append(free, pos)
+		}
+	}
+
+	for i := 0; i < cfg.Monsters+depth-1 && len(free) > 0 && len(defs.Monsters) > 0; i++ {
+		pos := free[0]
+		free = free[1:]
+		// the stronger monsters further down the definitions get more common the deeper you go
+		kind := 0
+		for kind < len(defs.Monsters)-1 && r.Intn(10) < depth {
+			kind++
+		}
+		level.AddMonster(defs.Monsters[kind].New(pos, r))
+	}
+	for i := 0; i < cfg.Items && len(free) > 0 && len(defs.Items) > 0; i++ {
+		pos := free[0]
+		free = free[1:]
+		item := defs.Items[r.Intn(len(defs.Items))]
+		level.AddItem(item.New(pos))
+	}
+
+	return level, upStair, nil
+}
+
+// minSize is the smallest width and height with room for floor inside the border
+const minSize = 3
+
+type room struct {
+	x, y, w, h int
+}
+
+func (rm room) center() game.Pos {
+	return game.Pos{X: rm.x + rm.w/2, Y: rm.y + rm.h/2}
+}
+
+// overlaps also counts rooms that would share a wall as overlapping
+func (rm room) overlaps(other room) bool {
+	return rm.x-1 <= other.x+other.w && other.x-1 <= rm.x+rm.w &&
+		rm.y-1 <= other.y+other.h && other.y-1 <= rm.y+rm.h
+}
+
+func carveRooms(level *game.Level, r *rand.Rand) {
+	width := len(level.Map[0])
+	height := len(level.Map)
+	// rooms keep a tile of border on every side, on small maps they shrink to fit
+	maxW, maxH := width-3, height-3
+	var rooms []room
+	for tries := 0; tries < 200 && len(rooms) < 10; tries++ {
+		w := 4 + r.Intn(8)
+		h := 3 + r.Intn(5)
+		if w > maxW {
+			w = maxW
+		}
+		if h > maxH {
+			h = maxH
+		}
+		if w < 1 || h < 1 {
+			return // not even a single tile fits
+		}
+		rm := room{1 + r.Intn(width-w-2), 1 + r.Intn(height-h-2), w, h}
+		ok := true
+		for _, other := range rooms {
+			if rm.overlaps(other) {
+				ok = false
+				break
+			}
+		}
+		if !ok {
+			continue
+		}
+		for y := rm.y; y < rm.y+rm.h; y++ {
+			for x := rm.x; x < rm.x+rm.w; x++ {
+				level.Map[y][x].Rune = game.DirtFloor
+			}
+		}
+		rooms = append(rooms, rm)
+	}
+
+	// every room is connected to the one carved before it, so all of them are reachable
+	for i := 1; i < len(rooms); i++ {
+		from := rooms[i-1].center()
+		to := rooms[i].center()
+		if r.Intn(2) == 0 {
+			carveHorizontal(level, from.X, to.X, from.Y)
+			carveVertical(level, from.Y, to.Y, to.X)
+		} else {
+			carveVertical(level, from.Y, to.Y, from.X)
+			carveHorizontal(level, from.X, to.X, to.Y)
+		}
+	}
+
+	for _, rm := range rooms {
+		addDoors(level, rm, r)
+	}
+}
+
+func carveHorizontal(level *game.Level, x1, x2, y int) {
+	if x1 > x2 {
+		x1, x2 = x2, x1
+	}
+	for x := x1; x <= x2; x++ {
+		level.Map[y][x].Rune = game.DirtFloor
+	}
+}
+
+func carveVertical(level *game.Level, y1, y2, x int) {
+	if y1 > y2 {
+		y1, y2 = y2, y1
+	}
+	for y := y1; y <= y2; y++ {
+		level.Map[y][x].Rune = game.DirtFloor
+	}
+}
+
+// addDoors puts doors where corridors leave a room through a one tile wide gap
+func addDoors(level *game.Level, rm room, r *rand.Rand) {
+	isFloor := func(x, y int) bool {
+		return y >= 0 && y < len(level.Map) && x >= 0 && x < len(level.Map[0]) && level.Map[y][x].Rune == game.DirtFloor
+	}
+	for y := rm.y - 1; y <= rm.y+rm.h; y++ {
+		for x := rm.x - 1; x <= rm.x+rm.w; x++ {
+			onBorder := y == rm.y-1 || y == rm.y+rm.h || x == rm.x-1 || x == rm.x+rm.w
+			if !onBorder || !isFloor(x, y) {
+				continue
+			}
+			horizontalGap := !isFloor(x-1, y) && !isFloor(x+1, y)
+			verticalGap := !isFloor(x, y-1) && !isFloor(x, y+1)
+			if (horizontalGap || verticalGap) && r.Intn(3) > 0 {
+				level.Map[y][x].OverlayRune = game.ClosedDoor
+			}
+		}
+	}
+}
+
+// carveCave runs a few rounds of cellular automata over random noise and keeps the biggest open area
+func carveCave(level *game.Level, r *rand.Rand) {
+	width := len(level.Map[0])
+	height := len(level.Map)
+	wall := make([][]bool, height)
+	for y := range wall {
+		wall[y] = make([]bool, width)
+		for x := range wall[y] {
+			wall[y][x] = x == 0 || y == 0 || x == width-1 || y == height-1 || r.Intn(100) < 45
+		}
+	}
+
+	for step := 0; step < 5; step++ {
+		next := make([][]bool, height)
+		for y := range next {
+			next[y] = make([]bool, width)
+			for x := range next[y] {
+				if x == 0 || y == 0 || x == width-1 || y == height-1 {
+					next[y][x] = true
+					continue
+				}
+				walls := 0
+				for dy := -1; dy <= 1; dy++ {
+					for dx := -1; dx <= 1; dx++ {
+						if wall[y+dy][x+dx] {
+							walls++
+						}
+					}
+				}
+				next[y][x] = walls >= 5
+			}
+		}
+		wall = next
+	}
+
+	// flood fill every open area and only carve the largest one, so every floor tile is reachable
+	region := make([][]int, height)
+	for y := range region {
+		region[y] = make([]int, width)
+	}
+	biggest, biggestSize := 0, 0
+	regions := 0
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if wall[y][x] || region[y][x] != 0 {
+				continue
+			}
+			regions++
+			size := 0
+			frontier := []game.Pos{{X: x, Y: y}}
+			region[y][x] = regions
+			for len(frontier) > 0 {
+				current := frontier[0]
+				frontier = frontier[1:]
+				size++
+				for _, next := range []game.Pos{{X: current.X + 1, Y: current.Y}, {X: current.X - 1, Y: current.Y},
+					{X: current.X, Y: current.Y + 1}, {X: current.X, Y: current.Y - 1}} {
+					if !wall[next.Y][next.X] && region[next.Y][next.X] == 0 {
+						region[next.Y][next.X] = regions
+						frontier = append(frontier, next)
+					}
+				}
+			}
+			if size > biggestSize {
+				biggest, biggestSize = regions, size
+			}
+		}
+	}
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if region[y][x] == biggest && biggest != 0 {
+				level.Map[y][x].Rune = game.DirtFloor
+			}
+		}
+	}
+}
+
+// addWalls surrounds every floor tile with stone, what's left stays blank like outside of the hand-drawn maps
+func addWalls(level *game.Level) {
+	for y, row := range level.Map {
+		for x := range row {
+			if level.Map[y][x].Rune != game.Blank {
+				continue
+			}
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					ny, nx := y+dy, x+dx
+					if ny >= 0 && ny < len(level.Map) && nx >= 0 && nx < len(row) && level.Map[ny][nx].Rune == game.DirtFloor {
+						level.Map[y][x].Rune = game.StoneWall
+					}
+				}
+			}
+		}
+	}
+}
+
+func floorTiles(level *game.Level) []game.Pos {
+	var floor []game.Pos
+	for y, row := range level.Map {
+		for x, tile := range row {
+			if tile.Rune == game.DirtFloor && tile.OverlayRune == game.Blank {
+				floor = append(floor, game.Pos{X: x, Y: y})
+			}
+		}
+	}
+	return floor
+}
+
+func farthest(from game.Pos, candidates []game.Pos) game.Pos {
+	best := candidates[0]
+	for _, pos := range candidates {
+		if manhattan(from, pos) > manhattan(from, best) {
+			best = pos
+		}
+	}
+	return best
+}
+
+func manhattan(a, b game.Pos) int {
+	dx := a.X - b.X
+	if dx < 0 {
+		dx = -dx
+	}
+	dy := a.Y - b.Y
+	if dy < 0 {
+		dy = -dy
+	}
+	return dx + dy
+}
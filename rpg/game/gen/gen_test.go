@@ -0,0 +1,78 @@
+package gen
+
+import (
+	"gameswithgo/rpg/game"
+	"reflect"
+	"testing"
+)
+
+// TestGenerateSizes makes levels of every small size and a few big ones, each of them either gets
+// both stairs or an error, never a panic
+func TestGenerateSizes(t *testing.T) {
+	defs := game.DefaultDefinitions()
+	var sizes [][2]int
+	for w := 0; w <= 16; w++ {
+		for h := 0; h <= 12; h++ {
+			sizes = append(sizes, [2]int{w, h})
+		}
+	}
+	sizes = append(sizes, [2]int{60, 30}, [2]int{12, 8}, [2]int{10, 10}, [2]int{100, 5})
+
+	for _, size := range sizes {
+		for _, style := range []Style{RoomsAndCorridors, Cave} {
+			for seed := int64(0); seed < 20; seed++ {
+				cfg := DefaultConfig()
+				cfg.Width, cfg.Height, cfg.Style = size[0], size[1], style
+				level, upStair, err := Generate(seed, 1+int(seed%3), cfg, &game.Player{}, defs)
+				if err != nil {
+					if size[0] >= 12 && size[1] >= 8 {
+						t.Errorf("%dx%d style %d seed %d: %v", size[0], size[1], style, seed, err)
+					}
+					continue
+				}
+				if got := level.Map[upStair.Y][upStair.X].OverlayRune; got != game.UpStair {
+					t.Errorf("%dx%d style %d seed %d: no up stair at %v", size[0], size[1], style, seed, upStair)
+				}
+				if !hasDownStair(level) {
+					t.Errorf("%dx%d style %d seed %d: no down stair", size[0], size[1], style, seed)
+				}
+			}
+		}
+	}
+}
+
+func hasDownStair(level *game.Level) bool {
+	for _, row := range level.Map {
+		for _, tile := range row {
+			if tile.OverlayRune == game.DownStair {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// TestStairsLeadToDifferentLevels generates the levels below two down stairs of one level
+func TestStairsLeadToDifferentLevels(t *testing.T) {
+	from := game.NewLevel(10, 10, &game.Player{})
+	from.Name = "level1"
+	generate := New(7)
+	a, _, err := generate(1, game.LevelPos{Level: from, Pos: game.Pos{X: 2, Y: 3}}, game.DefaultDefinitions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _, err := generate(1, game.LevelPos{Level: from, Pos: game.Pos{X: 6, Y: 3}}, game.DefaultDefinitions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reflect.DeepEqual(a.Map, b.Map) {
+		t.Error("two down stairs of the same level lead to the same level")
+	}
+	again, _, err := generate(1, game.LevelPos{Level: from, Pos: game.Pos{X: 2, Y: 3}}, game.DefaultDefinitions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(a.Map, again.Map) {
+		t.Error("the same stair led to a different level the second time")
+	}
+}
@@ -1,22 +1,129 @@
 package game
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
 type ItemType int
+
 const (
 	Weapon ItemType = iota
 	Helmet
 	Other
+	Consumable
+	Armor
+	Shield
+	Boots
+	Ring
+	Amulet
+)
+
+// Effect is what using up a consumable does
+type Effect int
+
+const (
+	NoEffect  Effect = iota
+	Heal             // gives back Power hitpoints
+	Teleport         // puts the user on a random free tile of the level
+	RevealMap        // marks every tile of the level as seen
 )
 
 type Item struct {
 	Typ ItemType
 	Entity
-	Power float64
+	Power  float64 // what a consumable's effect is worth
+	Mods   Stats   // added to the wearer's stats while equipped
+	Effect Effect
+	Count  int // consumables of the same name stack up in the backpack, this many of them
+}
+
+// Describe tells what the item is and does in one line, for tooltips and item lists
+func (item *Item) Describe() string {
+	text := item.Name
+	if item.Count > 1 {
+		text += " x" + strconv.Itoa(item.Count)
+	}
+	if item.Typ == Consumable {
+		switch item.Effect {
+		case Heal:
+			return text + ": heals " + strconv.Itoa(int(item.Power))
+		case Teleport:
+			return text + ": teleports you"
+		case RevealMap:
+			return text + ": reveals the level"
+		}
+		return text
+	}
+	var mods []string
+	if item.Mods.Attack != 0 {
+		mods = append(mods, fmt.Sprintf("%+d attack", item.Mods.Attack))
+	}
+	if item.Mods.Defense != 0 {
+		mods = append(mods, fmt.Sprintf("%+d defense", item.Mods.Defense))
+	}
+	if item.Mods.Speed != 0 {
+		mods = append(mods, fmt.Sprintf("%+g speed", item.Mods.Speed))
+	}
+	if item.Mods.SightRange != 0 {
+		mods = append(mods, fmt.Sprintf("%+d sight", item.Mods.SightRange))
+	}
+	if len(mods) > 0 {
+		text += ": " + strings.Join(mods, ", ")
+	}
+	return text
 }
 
-func NewSword(p Pos) *Item{
-	return &Item{Weapon,Entity{p, "Sword", 's'}, 2.0}
+// UseItem applies a consumable from the character's backpack and takes one off its stack
+func (level *Level) UseItem(id EntityID, character *Character) error {
+	for i, item := range character.Items {
+		if item.ID != id {
+			continue
+		}
+		if item.Typ != Consumable {
+			return &EntityError{id, "can't be used"}
+		}
+		switch item.Effect {
+		case Heal:
+			character.Hitpoints += int(item.Power)
+			if character.Hitpoints > character.MaxHitpoints {
+				character.Hitpoints = character.MaxHitpoints
+			}
+		case Teleport:
+			free := level.freeTiles()
+			if len(free) > 0 {
+				character.Pos = free[level.random().Intn(len(free))]
+			}
+		case RevealMap:
+			for y, row := range level.Map {
+				for x, tile := range row {
+					if tile.Rune != Blank {
+						level.Map[y][x].Seen = true
+					}
+				}
+			}
+		}
+		item.Count--
+		if item.Count <= 0 {
+			character.Items = append(character.Items[:i], character.Items[i+1:]...)
+		}
+		level.AddMessage(Loot, character.Name+" used: "+item.Name, character.ID, item.ID)
+		return nil
+	}
+	return &EntityError{id, "isn't in " + character.Name + "'s backpack"}
 }
 
-func NewHelmet(p Pos) *Item{
-	return &Item{Helmet,Entity{p, "Helmet", 'h'},.1} // here power = dmg reduction
+// freeTiles lists the tiles anyone could stand on, leaving out the player's and the portals
+func (level *Level) freeTiles() []Pos {
+	var free []Pos
+	for y, row := range level.Map {
+		for x := range row {
+			pos := Pos{x, y}
+			if canWalk(level, pos) && level.PlayerAt(pos) == nil && level.Portals[pos] == nil {
+				free = append(free, pos)
+			}
+		}
+	}
+	return free
 }
@@ -0,0 +1,19 @@
+package game
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// the maps shipped with the game, so a built binary doesn't depend on the directory it is started from
+//
+//go:embed maps
+var embeddedMaps embed.FS
+
+func DefaultMaps() fs.FS {
+	maps, err := fs.Sub(embeddedMaps, "maps")
+	if err != nil {
+		panic(err)
+	}
+	return maps
+}
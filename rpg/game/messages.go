@@ -0,0 +1,117 @@
+package game
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+type MessageKind int
+
+const (
+	General MessageKind = iota
+	Combat
+	Loot
+	Door
+	Progress
+	System // saving, loading and other things outside of the game world
+)
+
+func (k MessageKind) String() string {
+	switch k {
+	case Combat:
+		return "combat"
+	case Loot:
+		return "loot"
+	case Door:
+		return "door"
+	case Progress:
+		return "progress"
+	case System:
+		return "system"
+	}
+	return "general"
+}
+
+type Message struct {
+	Turn int
+	Time time.Time
+	Kind MessageKind
+	Text string
+	// About lists the entities the message is about, whoever acted first, so front ends can
+	// point them out without parsing Text
+	About []EntityID
+}
+
+// MessageLog is everything that happened in a game, shared by all of its levels.
+// At least the last maxMessages are kept, the older ones are dropped in batches.
+type MessageLog struct {
+	Messages []Message
+	Total    int // messages ever added, including the ones dropped
+}
+
+const maxMessages = 1000
+
+// Add appends msg, once the log holds twice maxMessages it is cut back to the newest maxMessages
+// in a new slice, so the copying is spread over maxMessages messages
+func (log *MessageLog) Add(msg Message) {
+	log.Messages = append(log.Messages, msg)
+	log.Total++
+	if len(log.Messages) > 2*maxMessages {
+		log.Messages = append(make([]Message, 0, 2*maxMessages), log.Messages[len(log.Messages)-maxMessages:]...)
+	}
+}
+
+// Last returns up to n of the newest messages, oldest first
+func (log *MessageLog) Last(n int) []Message {
+	if n > len(log.Messages) {
+		n = len(log.Messages)
+	}
+	return log.Messages[len(log.Messages)-n:]
+}
+
+// Since returns the messages added after the log had seen total messages
+func (log *MessageLog) Since(total int) []Message {
+	n := log.Total - total
+	if n < 0 {
+		n = 0
+	}
+	return log.Last(n)
+}
+
+// Export writes the log as plain text, one message per line, for attaching to bug reports
+func (log *MessageLog) Export(w io.Writer) error {
+	for _, msg := range log.Messages {
+		_, err := fmt.Fprintf(w, "%s  turn %4d  %-8s  %s\n", msg.Time.Format("2006-01-02 15:04:05"), msg.Turn, msg.Kind, msg.Text)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddMessage logs what just happened on the level to the entities about, stamped with the player's turn
+func (level *Level) AddMessage(kind MessageKind, text string, about ...EntityID) {
+	turn := 0
+	if level.Player != nil {
+		turn = level.Player.Turns
+	}
+	level.Log.Add(Message{Turn: turn, Time: time.Now(), Kind: kind, Text: text, About: about})
+}
+
+func (game *Game) exportLog() {
+	file, err := os.Create(game.LogPath)
+	if err == nil {
+		err = game.Log.Export(file)
+		closeErr := file.Close()
+		if err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		game.CurrentLevel.AddMessage(System, "Exporting the log failed: "+err.Error())
+		return
+	}
+	game.CurrentLevel.AddMessage(System, "Log exported to "+game.LogPath)
+}
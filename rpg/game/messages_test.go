@@ -0,0 +1,26 @@
+package game
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestMessageLogTrim(t *testing.T) {
+	var log MessageLog
+	for i := 1; i <= 2*maxMessages+1; i++ {
+		log.Add(Message{Text: strconv.Itoa(i)})
+		if len(log.Messages) > 2*maxMessages {
+			t.Fatalf("log holds %d messages after %d", len(log.Messages), i)
+		}
+	}
+	if len(log.Messages) != maxMessages {
+		t.Errorf("log holds %d messages after being cut back, want %d", len(log.Messages), maxMessages)
+	}
+	if log.Total != 2*maxMessages+1 {
+		t.Errorf("log counted %d messages, want %d", log.Total, 2*maxMessages+1)
+	}
+	last := log.Since(log.Total - 3)
+	if len(last) != 3 || last[2].Text != strconv.Itoa(2*maxMessages+1) || last[0].Text != strconv.Itoa(2*maxMessages-1) {
+		t.Errorf("the newest messages are %v", last)
+	}
+}
@@ -1,100 +1,75 @@
 package game
 
-import "fmt"
+import "sort"
 
 type Monster struct {
 	Character
+	Behaviour Behaviour
+	State     AIState
+	Post      Pos // where a guarding monster returns to
+	LastSeen  Pos // where the player was last spotted while chasing
+	XP        int // what the player learns from killing it
 }
 
+// Kill removes the monster, drops what it carried and wore and credits the player with the kill
 func (m *Monster) Kill(level *Level) {
-	delete(level.Monsters, m.Pos)
-	groundItems := level.Items[m.Pos]
-	for _, item := range m.Items {
+	level.removeMonster(m)
+	level.Player.Kills++
+	level.Player.GainXP(m.XP, level)
+	for _, item := range append(m.Items, m.Equipment()...) {
 		item.Pos = m.Pos
-		groundItems = append(groundItems, item)
+		level.putItem(item)
 	}
-	level.Items[m.Pos] = groundItems
 }
 
-func NewRat(p Pos) *Monster {
-	//return &Monster{Pos:p, Rune:'R', Name: "Rat", Hitpoints:5, Strength:5, Speed:1.5, ActionPoints:0.0}
-	return &Monster{
-		Character{
-			Entity: Entity{
-				Pos:  p,
-				Name: "Rat",
-				Rune: 'R',
-			},
-			Hitpoints:    50,
-			Strength:     1,
-			Speed:        2.0,
-			ActionPoints: 0.0,
-			SightRange: 10,
-			Items:[]*Item{NewHelmet(p)},
-		},
+// SortedMonsters returns the monsters top to bottom, left to right so turns are taken in a stable order
+func (level *Level) SortedMonsters() []*Monster {
+	monsters := make([]*Monster, 0, len(level.Monsters))
+	for _, monster := range level.Monsters {
+		monsters = append(monsters, monster)
 	}
+	sort.Slice(monsters, func(i, j int) bool {
+		return monsters[i].Pos.Less(monsters[j].Pos)
+	})
+	return monsters
 }
 
-func NewSpider(p Pos) *Monster {
-	//return &Monster{p, 'S', "Spider", 10, 10, 1.0, .0}
-	return &Monster{Character{
-		Entity: Entity{
-			Pos:  p,
-			Name: "Spider",
-			Rune: 'S',
-		},
-		Hitpoints:    100,
-		Strength:     1,
-		Speed:        1.0,
-		ActionPoints: 0.0,
-		SightRange: 10,
-		Items: []*Item{NewSword(p)},
-	}}
-}
-
-func (m *Monster) Update(level *Level) {
-	m.ActionPoints += m.Speed
+// Update lets the monster take a single action and returns how many action points it cost,
+// monsters without a behaviour run straight at the player from anywhere on the level
+func (m *Monster) Update(level *Level) float64 {
+	if m.Behaviour != nil {
+		return m.Behaviour.Act(m, level)
+	}
 	playerPos := level.Player.Pos
-
-	apInt := int(m.ActionPoints)
 	positions := level.astar(m.Pos, playerPos)
 
-	if len(positions) == 0 {
-		m.Pass()
-		return
-	}
-
-	moveIndex := 1
-	for i := 0; i < apInt; i++ {
-		if moveIndex < len(positions) {
-			m.Move(positions[moveIndex], level)
-			moveIndex++
-			m.ActionPoints--
-		}
+	if len(positions) < 2 {
+		return m.Pass()
 	}
+	return m.Move(positions[1], level)
 }
 
-func (m *Monster) Pass() {
-	m.ActionPoints -= m.Speed
+func (m *Monster) Pass() float64 {
+	return WaitCost
 }
 
-func (m *Monster) Move(to Pos, level *Level) {
+func (m *Monster) Move(to Pos, level *Level) float64 {
 	_, exists := level.Monsters[to]
+	player := level.PlayerAt(to)
 
 	// TODO check if tile being moved to is valid
-	if !exists && to != level.Player.Pos {
-		delete(level.Monsters, m.Pos)
-		level.Monsters[to] = m
-		m.Pos = to
-	} else if to == level.Player.Pos{
-		level.Attack(&m.Character, &level.Player.Character)
-		fmt.Println("Monster attacked player")
-		fmt.Println(m.Hitpoints, level.Player.Hitpoints)
+	if !exists && player == nil {
+		level.moveMonster(m, to)
+		return MoveCost
+	} else if player != nil {
+		level.Attack(&m.Character, &player.Character)
 		if m.Hitpoints <= 0 {
-			delete(level.Monsters, m.Pos)
+			m.Kill(level)
 		}
-		if level.Player.Hitpoints <= 0 {
-			panic("YOU DIED")
+		if player.Hitpoints <= 0 {
+			player.KilledBy = m.Name
 		}
+		return AttackCost
 	}
+	return m.Pass()
 }
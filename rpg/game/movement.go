@@ -0,0 +1,89 @@
+package game
+
+import "math"
+
+// directions maps the movement inputs to the step they take
+var directions = map[InputType]Pos{
+	Up:        {0, -1},
+	Down:      {0, 1},
+	Left:      {-1, 0},
+	Right:     {1, 0},
+	UpLeft:    {-1, -1},
+	UpRight:   {1, -1},
+	DownLeft:  {-1, 1},
+	DownRight: {1, 1},
+}
+
+// the eight steps in the order neighbours are tried, straight ones first
+var steps = []Pos{{1, 0}, {-1, 0}, {0, -1}, {0, 1}, {1, -1}, {-1, -1}, {1, 1}, {-1, 1}}
+
+func (p Pos) Add(d Pos) Pos {
+	return Pos{p.X + d.X, p.Y + d.Y}
+}
+
+// Less orders positions row by row, the order levels list their monsters, items, locks and the like in
+func (p Pos) Less(q Pos) bool {
+	if p.Y != q.Y {
+		return p.Y < q.Y
+	}
+	return p.X < q.X
+}
+
+func isWall(level *Level, pos Pos) bool {
+	if !inRange(level, pos) {
+		return true
+	}
+	switch level.Map[pos.Y][pos.X].Rune {
+	case StoneWall, Blank:
+		return true
+	}
+	return false
+}
+
+// canStep checks the corner rule for a step to a neighbouring tile: with strict corners a diagonal
+// step can't squeeze past a wall on either side
+func (level *Level) canStep(from, to Pos) bool {
+	if !level.strictCorners || from.X == to.X || from.Y == to.Y {
+		return true
+	}
+	return !isWall(level, Pos{to.X, from.Y}) && !isWall(level, Pos{from.X, to.Y})
+}
+
+func getNeighbors(level *Level, pos Pos) []Pos {
+	neighbors := make([]Pos, 0, 8)
+	for _, step := range steps {
+		next := pos.Add(step)
+		if canWalk(level, next) && level.canStep(pos, next) {
+			neighbors = append(neighbors, next)
+		}
+	}
+	return neighbors
+}
+
+// terrainCost is what walking onto pos costs, a door or stair counts rather than the floor under it
+func (level *Level) terrainCost(pos Pos) float64 {
+	t := level.Map[pos.Y][pos.X]
+	if cost, ok := level.terrain[t.OverlayRune]; ok && t.OverlayRune != Blank {
+		return cost
+	}
+	if cost, ok := level.terrain[t.Rune]; ok {
+		return cost
+	}
+	return 1
+}
+
+// stepCost is the terrain cost of next, times √2 for a diagonal step
+func (level *Level) stepCost(current, next Pos) float64 {
+	cost := level.terrainCost(next)
+	if current.X != next.X && current.Y != next.Y {
+		cost *= math.Sqrt2
+	}
+	return cost
+}
+
+// octile is the cheapest possible cost between two tiles when every tile costs at least 1
+func octile(a, b Pos) float64 {
+	dx := math.Abs(float64(a.X - b.X))
+	dy := math.Abs(float64(a.Y - b.Y))
+	return dx + dy + (math.Sqrt2-2)*math.Min(dx, dy)
+}
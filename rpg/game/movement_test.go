@@ -0,0 +1,38 @@
+package game
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTerrainCostDetour(t *testing.T) {
+	level, err := LoadLevel("doorway", strings.NewReader(""+
+		"#######\n"+
+		"#@./..#\n"+
+		"#.....#\n"+
+		"#######\n"), DefaultDefinitions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	start, goal, door := Pos{1, 1}, Pos{5, 1}, Pos{3, 1}
+	through := func(path []Pos) bool {
+		for _, pos := range path {
+			if pos == door {
+				return true
+			}
+		}
+		return false
+	}
+
+	if path := level.astar(start, goal); !through(path) {
+		t.Errorf("with every tile costing 1 the straight way through the door is shortest, got %v", path)
+	}
+	level.terrain = map[rune]float64{OpenDoor: 5}
+	path := level.astar(start, goal)
+	if through(path) {
+		t.Errorf("a door costing 5 should be walked around, got %v", path)
+	}
+	if len(path) == 0 || path[len(path)-1] != goal {
+		t.Errorf("the detour should still reach %v, got %v", goal, path)
+	}
+}
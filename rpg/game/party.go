@@ -0,0 +1,143 @@
+package game
+
+// A game can have several players, they form a party that is always on the current level.
+// Game.Players lists the party, the first one is the player from the maps, the others join with
+// AddPlayer. Level.Player is the player the level is dealing with right now: the one whose input
+// is being handled or, while a monster acts, the one it is after.
+
+// setActing makes p the player the levels deal with
+func (game *Game) setActing(p *Player) {
+	for _, level := range game.Levels {
+		level.Player = p
+	}
+}
+
+func (game *Game) InParty(p *Player) bool {
+	for _, other := range game.Players {
+		if other == p {
+			return true
+		}
+	}
+	return false
+}
+
+// AddPlayer puts a new character called name into the party, next to the player acting right now
+func (game *Game) AddPlayer(name string) *Player {
+	level := game.CurrentLevel
+	p := newPlayer()
+	p.ID = game.ids.next()
+	p.Name = name
+	p.Pos = level.freeTileNear(level.Player.Pos)
+	game.Players = append(game.Players, p)
+	game.attachLevels()
+	level.AddMessage(General, name+" joined the game", p.ID)
+	level.lineOfSight()
+	game.record(RecordedInput{Turn: p.Turns, Player: len(game.Players) - 1, Joined: name})
+	return p
+}
+
+// RemovePlayer takes p out of the party, the last player always stays
+func (game *Game) RemovePlayer(p *Player) {
+	if len(game.Players) < 2 || !game.InParty(p) {
+		return
+	}
+	entry := RecordedInput{Turn: p.Turns, Player: game.playerIndex(p), Left: true}
+	game.removePlayer(p)
+	game.record(entry)
+}
+
+func (game *Game) removePlayer(p *Player) {
+	party := make([]*Player, 0, len(game.Players)-1)
+	for _, other := range game.Players {
+		if other != p {
+			party = append(party, other)
+		}
+	}
+	game.Players = party
+	if game.CurrentLevel.Player == p {
+		game.setActing(party[0])
+	}
+	game.attachLevels()
+	game.CurrentLevel.lineOfSight()
+}
+
+// checkDeaths takes dead players out of the party, the game is only over when the last one dies
+func (game *Game) checkDeaths() {
+	for _, p := range append([]*Player(nil), game.Players...) {
+		if p.Hitpoints > 0 {
+			continue
+		}
+		if len(game.Players) == 1 {
+			if !game.Dead {
+				game.setActing(p)
+				game.gameOver()
+			}
+			return
+		}
+		if p.KilledBy != "" {
+			game.CurrentLevel.AddEvent(p.Name + " was killed by " + p.KilledBy)
+		} else {
+			game.CurrentLevel.AddEvent(p.Name + " died")
+		}
+		game.removePlayer(p)
+	}
+}
+
+// party is everyone on the level, a level that isn't part of a game only knows its Player
+func (level *Level) party() []*Player {
+	if len(level.Players) == 0 {
+		return []*Player{level.Player}
+	}
+	return level.Players
+}
+
+// PlayerAt returns the living player standing on pos
+func (level *Level) PlayerAt(pos Pos) *Player {
+	for _, p := range level.party() {
+		if p.Pos == pos && p.Hitpoints > 0 {
+			return p
+		}
+	}
+	return nil
+}
+
+// nearestPlayer is the living player a monster at pos goes after
+func (level *Level) nearestPlayer(pos Pos) *Player {
+	var nearest *Player
+	for _, p := range level.party() {
+		if p.Hitpoints > 0 && (nearest == nil || distanceSq(pos, p.Pos) < distanceSq(pos, nearest.Pos)) {
+			nearest = p
+		}
+	}
+	return nearest
+}
+
+// freeTileNear searches breadth-first for the closest tile to pos nobody stands on
+func (level *Level) freeTileNear(pos Pos) Pos {
+	frontier := []Pos{pos}
+	visited := map[Pos]bool{pos: true}
+	for len(frontier) > 0 {
+		current := frontier[0]
+		frontier = frontier[1:]
+		if current != pos && level.PlayerAt(current) == nil && level.Portals[current] == nil {
+			return current
+		}
+		for _, next := range getNeighbors(level, current) {
+			if !visited[next] {
+				frontier = append(frontier, next)
+				visited[next] = true
+			}
+		}
+	}
+	return pos
+}
+
+// gatherParty brings the rest of the party through a portal right behind the player who took it
+func (game *Game) gatherParty(leader *Player) {
+	for _, p := range game.Players {
+		if p != leader && p.Hitpoints > 0 {
+			p.Pos = leader.Pos // out of the way first, so the free tiles are searched around the leader
+			p.Pos = game.CurrentLevel.freeTileNear(leader.Pos)
+		}
+	}
+}
@@ -2,12 +2,12 @@ package game
 
 type priorityPos struct {
 	Pos
-	priority int
+	priority float64
 }
 
 type pqueue []priorityPos
 
-func (pq pqueue) push(pos Pos, priority int) pqueue {
+func (pq pqueue) push(pos Pos, priority float64) pqueue {
 	newNode := priorityPos{pos, priority}
 	pq = append(pq, newNode)
 	newNodeIndex := len(pq) - 1
@@ -0,0 +1,334 @@
+package game
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+)
+
+// A recording is a header line and then a line per input handled, all JSON. Every line carries
+// the StateHash of the game right after it, so a replay can tell where it first went different.
+
+const recordingMagic = "gameswithgo-rpg-recording"
+
+type recordingHeader struct {
+	Magic         string
+	Seed          int64
+	MapDir        string `json:",omitempty"`
+	WorldFile     string `json:",omitempty"`
+	DefsFile      string `json:",omitempty"`
+	StrictCorners bool   `json:",omitempty"`
+	Hash          string // of the game before the first input
+}
+
+// RecordedInput is an Input as it went into the game
+type RecordedInput struct {
+	Turn   int // of the acting player before the input
+	Player int // index of the acting player in Game.Players
+	Typ    InputType
+	Pos    Pos
+	Item   EntityID `json:",omitempty"`
+	Slot   Slot     `json:",omitempty"`
+	Joined string   `json:",omitempty"` // no input but a new player of this name, see AddPlayer
+	Left   bool     `json:",omitempty"` // no input but the player leaving, see RemovePlayer
+	Hash   string   // StateHash after the input
+}
+
+// Recording is what ReadRecording read back
+type Recording struct {
+	recordingHeader
+	Inputs []RecordedInput
+}
+
+// ReplayError tells which input made a replay go different from the recording, Input 0 means the
+// game was already different before the first one
+type ReplayError struct {
+	Input int
+	Turn  int
+	Want  string
+	Got   string
+}
+
+func (e *ReplayError) Error() string {
+	if e.Input == 0 {
+		return fmt.Sprintf("replay starts out different from the recording (state %s, recorded %s), check the maps, definitions and seed", e.Got, e.Want)
+	}
+	return fmt.Sprintf("replay went different at input %d on turn %d: state %s, recorded %s", e.Input, e.Turn, e.Got, e.Want)
+}
+
+type recorder struct {
+	enc  *json.Encoder
+	file *os.File // closed by StopRecording, nil if the caller owns the writer
+	err  error    // the first write that failed, nothing is written after it
+}
+
+func (r *recorder) write(v interface{}) {
+	if r.err == nil {
+		r.err = r.enc.Encode(v)
+	}
+}
+
+// Record writes every input the game handles from now on to w, unbuffered so a crash loses nothing
+func (game *Game) Record(w io.Writer) error {
+	r := &recorder{enc: json.NewEncoder(w)}
+	opts := game.options
+	r.write(recordingHeader{recordingMagic, opts.Seed, opts.MapDir, opts.WorldFile, opts.DefsFile, opts.StrictCorners, game.StateHash()})
+	if r.err != nil {
+		return r.err
+	}
+	game.recorder = r
+	return nil
+}
+
+// RecordFile records into a new file at path, see Record
+func (game *Game) RecordFile(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = game.Record(file)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	game.recorder.file = file
+	return nil
+}
+
+// StopRecording stops writing inputs and returns the first error writing them ran into
+func (game *Game) StopRecording() error {
+	r := game.recorder
+	if r == nil {
+		return nil
+	}
+	game.recorder = nil
+	if r.file != nil {
+		if err := r.file.Close(); r.err == nil {
+			r.err = err
+		}
+	}
+	return r.err
+}
+
+// recordable are the inputs that change the game, the others only concern the windows watching it.
+// Loading is refused while recording, a replay couldn't know what was in the save file.
+func recordable(typ InputType) bool {
+	switch typ {
+	case None, CloseWindow, JoinGame, Spectate, LoadGame:
+		return false
+	}
+	return true
+}
+
+func (game *Game) playerIndex(p *Player) int {
+	for i, other := range game.Players {
+		if other == p {
+			return i
+		}
+	}
+	return -1
+}
+
+func (game *Game) record(entry RecordedInput) {
+	if game.recorder != nil {
+		entry.Hash = game.StateHash()
+		game.recorder.write(entry)
+	}
+}
+
+// ReadRecording reads a recording made by Record. A recording cut short still comes back
+// together with the error, with the inputs up to where it breaks off.
+func ReadRecording(r io.Reader) (*Recording, error) {
+	dec := json.NewDecoder(bufio.NewReader(r))
+	var rec Recording
+	if err := dec.Decode(&rec.recordingHeader); err != nil {
+		return nil, err
+	}
+	if rec.Magic != recordingMagic {
+		return nil, errors.New("not a recording")
+	}
+	for {
+		var entry RecordedInput
+		err := dec.Decode(&entry)
+		if err == io.EOF {
+			return &rec, nil
+		}
+		if err != nil {
+			return &rec, fmt.Errorf("input %d: %w", len(rec.Inputs)+1, err)
+		}
+		rec.Inputs = append(rec.Inputs, entry)
+	}
+}
+
+// ReadRecordingFile reads the recording at path, see ReadRecording
+func ReadRecordingFile(path string) (*Recording, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ReadRecording(file)
+}
+
+// Options are the options the recorded game was started with, the caller has to add the
+// Generator and Maps if the game used them
+func (rec *Recording) Options() Options {
+	return Options{MapDir: rec.MapDir, WorldFile: rec.WorldFile, DefsFile: rec.DefsFile, Seed: rec.Seed, StrictCorners: rec.StrictCorners}
+}
+
+// Replay feeds the whole recording to a game started with its Options and checks the state after
+// every input, without a Run loop or windows. Made for tests and tools.
+func (game *Game) Replay(rec *Recording) error {
+	if got := game.StateHash(); got != rec.Hash {
+		return &ReplayError{0, 0, rec.Hash, got}
+	}
+	for i := range rec.Inputs {
+		if err := game.replayInput(rec, i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (game *Game) replayInput(rec *Recording, i int) error {
+	entry := rec.Inputs[i]
+	switch {
+	case entry.Joined != "":
+		game.AddPlayer(entry.Joined)
+	case entry.Left:
+		if entry.Player >= 0 && entry.Player < len(game.Players) {
+			game.RemovePlayer(game.Players[entry.Player])
+		}
+	case entry.Typ == SaveGame || entry.Typ == ExportLog:
+		// they only write files, which a replay must not overwrite
+	case entry.Typ == LoadGame:
+		return fmt.Errorf("input %d loads a save file, replays can't follow that", i+1)
+	default:
+		input := &Input{Typ: entry.Typ, Pos: entry.Pos, ItemID: entry.Item, Slot: entry.Slot}
+		if entry.Player >= 0 && entry.Player < len(game.Players) {
+			input.Player = game.Players[entry.Player]
+		}
+		game.Handle(input)
+	}
+	if got := game.StateHash(); got != entry.Hash {
+		return &ReplayError{i + 1, entry.Turn, entry.Hash, got}
+	}
+	return nil
+}
+
+// replay plays a recording back in Run, a step per tick, until the player takes over
+type replay struct {
+	rec    *Recording
+	next   int
+	ticker *time.Ticker
+}
+
+// StartReplay makes Run play rec back an input per tick before handing the game to the player,
+// any input from the player ends it early. Differences from the recording are logged.
+func (game *Game) StartReplay(rec *Recording, tick time.Duration) {
+	game.replay = &replay{rec: rec, ticker: time.NewTicker(tick)}
+	if got := game.StateHash(); got != rec.Hash {
+		game.stopReplay((&ReplayError{0, 0, rec.Hash, got}).Error())
+	}
+}
+
+func (game *Game) stopReplay(msg string) {
+	game.replay.ticker.Stop()
+	game.replay = nil
+	game.CurrentLevel.AddMessage(System, msg)
+}
+
+// replayTick fires when the next recorded input is due, never without a replay
+func (game *Game) replayTick() <-chan time.Time {
+	if game.replay == nil {
+		return nil
+	}
+	return game.replay.ticker.C
+}
+
+// replayStep plays the next recorded input, it returns false once the replay is over
+func (game *Game) replayStep() bool {
+	r := game.replay
+	if r == nil {
+		return false
+	}
+	if r.next >= len(r.rec.Inputs) {
+		game.stopReplay("Replay finished, the game matches the recording")
+		return false
+	}
+	err := game.replayInput(r.rec, r.next)
+	r.next++
+	if err != nil {
+		game.stopReplay(err.Error())
+		return false
+	}
+	return true
+}
+
+// StateHash sums up everything that decides how the game goes on: the levels, who and what is on
+// them, the party and how often the dice were rolled. The message log is left out, it doesn't
+// change what happens next.
+func (game *Game) StateHash() string {
+	type posItems struct {
+		Pos   Pos
+		Items []*Item
+	}
+	type fired struct {
+		Pos   Pos
+		Fired int
+	}
+	type levelState struct {
+		Name     string
+		Map      [][]Tile
+		Monsters []*Monster
+		Items    []posItems
+		Locks    []*Lock
+		Triggers []fired
+		Traps    []*Trap
+		Secrets  []Pos
+	}
+	state := struct {
+		Current string
+		Dead    bool
+		Draws   uint64
+		Players []*Player
+		Levels  []levelState
+	}{game.levelName(game.CurrentLevel), game.Dead, game.dice.draws, game.Players, nil}
+
+	names := make([]string, 0, len(game.Levels))
+	for name := range game.Levels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		level := game.Levels[name]
+		ls := levelState{Name: name, Map: level.Map, Monsters: level.SortedMonsters(), Locks: level.sortedLocks(),
+			Traps: level.sortedTraps(), Secrets: level.sortedSecrets()}
+		for _, t := range level.sortedTriggers() {
+			ls.Triggers = append(ls.Triggers, fired{t.Pos, t.Fired})
+		}
+		for pos, items := range level.Items {
+			if len(items) > 0 {
+				ls.Items = append(ls.Items, posItems{pos, items})
+			}
+		}
+		sort.Slice(ls.Items, func(i, j int) bool {
+			return ls.Items[i].Pos.Less(ls.Items[j].Pos)
+		})
+		state.Levels = append(state.Levels, ls)
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		panic(err) // all plain data, can't happen
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,109 @@
+package game
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// record plays a short session with two players and returns what was recorded
+func record(t *testing.T) (*Recording, string) {
+	game, err := NewGame(0, Options{Seed: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := game.Record(&buf); err != nil {
+		t.Fatal(err)
+	}
+	bob := game.AddPlayer("Bob")
+	for _, typ := range []InputType{Left, Left, Up, Search, Right, DownRight} {
+		game.Handle(&Input{Typ: typ})
+		game.Handle(&Input{Typ: typ, Player: bob})
+	}
+	game.Handle(&Input{Typ: TravelTo, Pos: Pos{3, 17}})
+	for len(game.CurrentLevel.Player.Path) > 0 {
+		game.Handle(&Input{Typ: Travel})
+	}
+	if err := game.StopRecording(); err != nil {
+		t.Fatal(err)
+	}
+	rec, err := ReadRecording(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rec, game.StateHash()
+}
+
+func TestRecordReplay(t *testing.T) {
+	rec, want := record(t)
+	if len(rec.Inputs) == 0 {
+		t.Fatal("nothing was recorded")
+	}
+	game, err := NewGame(0, rec.Options())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := game.Replay(rec); err != nil {
+		t.Fatal(err)
+	}
+	if got := game.StateHash(); got != want {
+		t.Errorf("state hash after the replay is %s, the recorded game ended with %s", got, want)
+	}
+}
+
+func TestReplayInRun(t *testing.T) {
+	rec, want := record(t)
+	game, err := NewGame(1, rec.Options())
+	if err != nil {
+		t.Fatal(err)
+	}
+	levelChan := game.LevelChans[0]
+	game.StartReplay(rec, time.Millisecond)
+	done := make(chan bool)
+	go func() {
+		game.Run()
+		close(done)
+	}()
+
+	// the viewer draws every level it gets while the replay goes on
+	var last *Level
+	for level := range levelChan {
+		if level == last {
+			t.Fatal("the game sent the same level twice")
+		}
+		last = level
+		seen := 0
+		for _, row := range level.Map {
+			for _, tile := range row {
+				if tile.Seen {
+					seen++
+				}
+			}
+		}
+		if seen == 0 {
+			t.Fatal("the viewer sees nothing of the level")
+		}
+		if len(level.Log.Messages) > 0 && level.Log.Messages[len(level.Log.Messages)-1].Text == "Replay finished, the game matches the recording" {
+			break
+		}
+	}
+	game.InputChan <- &Input{Typ: QuitGame}
+	<-done
+	if got := game.StateHash(); got != want {
+		t.Errorf("state hash after the replay is %s, the recorded game ended with %s", got, want)
+	}
+}
+
+// dice that drift apart show up in the hash at once, not only once they change what happens
+func TestStateHashCoversDice(t *testing.T) {
+	game, err := NewGame(0, Options{Seed: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := game.StateHash()
+	game.rng.Intn(6)
+	if game.StateHash() == before {
+		t.Error("rolling the dice left the state hash as it was")
+	}
+}
@@ -0,0 +1,303 @@
+package game
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/rand"
+	"os"
+)
+
+// saveVersion is bumped whenever the layout of saveFile changes in a way older saves can't be read with
+const saveVersion = 1
+
+type saveHeader struct {
+	Magic   string
+	Version int
+}
+
+type saveMonster struct {
+	Character
+	State    AIState
+	Post     Pos
+	LastSeen Pos
+	XP       int
+}
+
+type savePortal struct {
+	Pos
+	ToLevel string
+	ToPos   Pos
+}
+
+type saveLevel struct {
+	Map       [][]Tile
+	Monsters  []saveMonster
+	Items     map[Pos][]*Item
+	Portals   []savePortal
+	LastEvent GameEvent
+	Depth     int
+	Locks     map[Pos]*Lock
+	Triggers  map[Pos]*Trigger
+	Traps     map[Pos]*Trap
+	Secrets   map[Pos]bool
+}
+
+// saveOptions are the Options a game was started with that can be written down, see Recording.Options.
+// Maps can't be, CustomMaps only tells that they were set.
+type saveOptions struct {
+	CustomMaps    bool
+	MapDir        string
+	WorldFile     string
+	DefsFile      string
+	Seed          int64
+	StrictCorners bool
+}
+
+// levels are saved by name so the shared players and portal targets can be stitched back together on load
+type saveFile struct {
+	CurrentLevel string
+	Players      []Player // the whole party in order
+	Acting       int      // index of the acting player in Players
+	Levels       map[string]saveLevel
+	Log          *MessageLog
+	Options      saveOptions
+	Dice         saveDice
+}
+
+type saveDice struct {
+	Seed  int64
+	Draws uint64 // how often they were rolled
+}
+
+func (game *Game) levelName(level *Level) string {
+	for name, l := range game.Levels {
+		if l == level {
+			return name
+		}
+	}
+	return ""
+}
+
+func (game *Game) Save(w io.Writer) error {
+	if game.CurrentLevel == nil {
+		return errors.New("nothing to save")
+	}
+	opts := game.options
+	save := saveFile{
+		CurrentLevel: game.levelName(game.CurrentLevel),
+		Acting:       game.playerIndex(game.CurrentLevel.Player),
+		Levels:       make(map[string]saveLevel),
+		Log:          game.Log,
+		Options:      saveOptions{opts.Maps != nil, opts.MapDir, opts.WorldFile, opts.DefsFile, opts.Seed, opts.StrictCorners},
+		Dice:         saveDice{game.dice.seed, game.dice.draws},
+	}
+	for _, p := range game.Players {
+		save.Players = append(save.Players, *p)
+	}
+
+	for name, level := range game.Levels {
+		sl := saveLevel{
+			Map:       level.Map,
+			Items:     level.Items,
+			LastEvent: level.LastEvent,
+			Depth:     level.Depth,
+			Locks:     level.Locks,
+			Triggers:  level.Triggers,
+			Traps:     level.Traps,
+			Secrets:   level.Secrets,
+		}
+		for _, monster := range level.Monsters {
+			sl.Monsters = append(sl.Monsters, saveMonster{monster.Character, monster.State, monster.Post, monster.LastSeen, monster.XP})
+		}
+		for pos, portal := range level.Portals {
+			toName := game.levelName(portal.Level)
+			if toName == "" {
+				return fmt.Errorf("portal at %v on level %s leads to an unknown level", pos, name)
+			}
+			sl.Portals = append(sl.Portals, savePortal{pos, toName, portal.Pos})
+		}
+		save.Levels[name] = sl
+	}
+
+	enc := gob.NewEncoder(w)
+	err := enc.Encode(saveHeader{"gameswithgo-rpg", saveVersion})
+	if err != nil {
+		return err
+	}
+	return enc.Encode(save)
+}
+
+// Load reads a game saved with Save. It is started with the options the saved game was started with,
+// without windows and without a Generator, so stairs that didn't lead anywhere yet still don't.
+// A game started with Options.Maps has to be loaded with LoadWith.
+func Load(r io.Reader) (*Game, error) {
+	return LoadWith(r, nil)
+}
+
+// LoadWith reads a game saved with Save like Load, taking the maps and definitions from maps if the
+// saved game was started with Options.Maps
+func LoadWith(r io.Reader, maps fs.FS) (*Game, error) {
+	save, err := readSave(r)
+	if err != nil {
+		return nil, err
+	}
+	saved := save.Options
+	if saved.CustomMaps && maps == nil {
+		return nil, errors.New("the game was started from maps that weren't saved with it, load it with the same maps")
+	}
+	if !saved.CustomMaps {
+		maps = nil
+	}
+	opts := Options{Maps: maps, MapDir: saved.MapDir, WorldFile: saved.WorldFile, DefsFile: saved.DefsFile, Seed: saved.Seed, StrictCorners: saved.StrictCorners}
+	defs, err := opts.Definitions()
+	if err != nil {
+		return nil, err
+	}
+	game := newGame(0, opts, defs)
+	err = game.restore(save)
+	if err != nil {
+		return nil, err
+	}
+	return game, nil
+}
+
+func readSave(r io.Reader) (*saveFile, error) {
+	dec := gob.NewDecoder(r)
+	var header saveHeader
+	err := dec.Decode(&header)
+	if err != nil {
+		return nil, err
+	}
+	if header.Magic != "gameswithgo-rpg" {
+		return nil, errors.New("not a save file")
+	}
+	if header.Version != saveVersion {
+		return nil, fmt.Errorf("unsupported save version %d (want %d)", header.Version, saveVersion)
+	}
+
+	var save saveFile
+	err = dec.Decode(&save)
+	if err != nil {
+		return nil, err
+	}
+	return &save, nil
+}
+
+// restore replaces the levels, party and dice of a game with the saved ones, the channels and options are left untouched
+func (game *Game) restore(save *saveFile) error {
+	if save.Acting < 0 || save.Acting >= len(save.Players) {
+		return fmt.Errorf("acting player %d not in the saved party of %d", save.Acting, len(save.Players))
+	}
+	party := make([]*Player, len(save.Players))
+	for i := range save.Players {
+		player := &save.Players[i]
+		player.Path = nil
+		player.Exploring = false
+		party[i] = player
+	}
+	player := party[save.Acting]
+	levels := make(map[string]*Level)
+	for name, sl := range save.Levels {
+		level := &Level{Name: name}
+		level.Debug = make(map[Pos]bool)
+		level.Player = player
+		level.Map = sl.Map
+		level.LastEvent = sl.LastEvent
+		level.Depth = sl.Depth
+		level.Locks = sl.Locks
+		level.Triggers = sl.Triggers
+		level.Traps = sl.Traps
+		level.Secrets = sl.Secrets
+		level.Monsters = make(map[Pos]*Monster)
+		level.Portals = make(map[Pos]*LevelPos)
+		level.Items = sl.Items
+		if level.Items == nil {
+			level.Items = make(map[Pos][]*Item)
+		}
+		for _, sm := range sl.Monsters {
+			monster := &Monster{Character: sm.Character, State: sm.State, Post: sm.Post, LastSeen: sm.LastSeen, XP: sm.XP}
+			monster.Behaviour = game.Defs.behaviour(monster.Name)
+			level.Monsters[monster.Pos] = monster
+		}
+		levels[name] = level
+	}
+
+	for name, sl := range save.Levels {
+		for _, sp := range sl.Portals {
+			to := levels[sp.ToLevel]
+			if to == nil {
+				return fmt.Errorf("portal on level %s leads to unknown level %s", name, sp.ToLevel)
+			}
+			levels[name].Portals[sp.Pos] = &LevelPos{to, sp.ToPos}
+		}
+	}
+
+	current := levels[save.CurrentLevel]
+	if current == nil {
+		return fmt.Errorf("current level %s not found in save", save.CurrentLevel)
+	}
+
+	if save.Log == nil {
+		save.Log = &MessageLog{} // gob leaves out a log without messages
+	}
+
+	game.Levels = levels
+	game.CurrentLevel = current
+	game.Dead = player.Hitpoints <= 0
+	game.Log = save.Log
+	game.Players = party
+	game.ids = &idSource{}
+	game.dice = rolledDice(save.Dice.Seed, save.Dice.Draws)
+	game.rng = rand.New(game.dice)
+	game.attachLevels()
+	return nil
+}
+
+func (game *Game) saveToFile() {
+	if game.Dead {
+		game.CurrentLevel.AddMessage(System, "Can't save, you are dead")
+		return
+	}
+	file, err := os.Create(game.SavePath)
+	if err == nil {
+		err = game.Save(file)
+		closeErr := file.Close()
+		if err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		game.CurrentLevel.AddMessage(System, "Saving failed: "+err.Error())
+		return
+	}
+	game.CurrentLevel.AddMessage(System, "Game saved")
+}
+
+func (game *Game) loadFromFile() {
+	if game.recorder != nil {
+		game.CurrentLevel.AddMessage(System, "No loading while recording, the replay couldn't follow it")
+		return
+	}
+	if game.replay != nil {
+		game.CurrentLevel.AddMessage(System, "No loading during a replay, the rest of it would play on another game")
+		return
+	}
+	file, err := os.Open(game.SavePath)
+	var save *saveFile
+	if err == nil {
+		defer file.Close()
+		save, err = readSave(file)
+	}
+	if err == nil {
+		err = game.restore(save)
+	}
+	if err != nil {
+		game.CurrentLevel.AddMessage(System, "Loading failed: "+err.Error())
+		return
+	}
+	game.CurrentLevel.AddMessage(System, "Game loaded")
+	game.CurrentLevel.lineOfSight()
+}
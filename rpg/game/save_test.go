@@ -0,0 +1,96 @@
+package game
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSaveLoad(t *testing.T) {
+	game, err := NewGame(0, Options{Seed: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	game.AddPlayer("Bob")
+	walk := []InputType{Right, Right, Down, Left, Search, Up, Right}
+	for _, typ := range walk {
+		game.Handle(&Input{Typ: typ})
+	}
+
+	var buf bytes.Buffer
+	if err := game.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Players) != len(game.Players) {
+		t.Fatalf("loaded a party of %d, saved %d", len(loaded.Players), len(game.Players))
+	}
+	for i, p := range loaded.Players {
+		if p.Name != game.Players[i].Name {
+			t.Errorf("player %d is %s, saved %s", i, p.Name, game.Players[i].Name)
+		}
+	}
+	if got, want := loaded.StateHash(), game.StateHash(); got != want {
+		t.Fatalf("state hash after loading is %s, want %s", got, want)
+	}
+
+	// the dice carry on where they were, so both games play out the same
+	for _, typ := range walk {
+		game.Handle(&Input{Typ: typ})
+		loaded.Handle(&Input{Typ: typ})
+		if got, want := loaded.StateHash(), game.StateHash(); got != want {
+			t.Fatalf("state hash after %v is %s, want %s", typ, got, want)
+		}
+	}
+}
+
+func TestLoadCustomMaps(t *testing.T) {
+	game, err := NewGame(0, Options{Maps: DefaultMaps(), Seed: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := game.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	saved := buf.Bytes()
+	if _, err := Load(bytes.NewReader(saved)); err == nil {
+		t.Error("loaded a game started from custom maps without them")
+	}
+	loaded, err := LoadWith(bytes.NewReader(saved), DefaultMaps())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := loaded.StateHash(), game.StateHash(); got != want {
+		t.Errorf("state hash after loading is %s, want %s", got, want)
+	}
+}
+
+func TestNoLoadDuringReplay(t *testing.T) {
+	rec, _ := record(t)
+	game, err := NewGame(0, rec.Options())
+	if err != nil {
+		t.Fatal(err)
+	}
+	game.SavePath = filepath.Join(t.TempDir(), "rpg.sav")
+	game.StartReplay(rec, time.Hour)
+	game.Handle(&Input{Typ: SaveGame})
+	game.replayStep()
+	game.replayStep()
+	want := game.StateHash()
+
+	game.Handle(&Input{Typ: LoadGame})
+	if got := game.StateHash(); got != want {
+		t.Errorf("loading went ahead during the replay, state %s is now %s", want, got)
+	}
+	if game.replay == nil {
+		t.Error("the replay stopped")
+	}
+	if last := game.Log.Last(1); len(last) != 1 || last[0].Kind != System {
+		t.Errorf("the player isn't told why the game wasn't loaded, last message %v", last)
+	}
+}
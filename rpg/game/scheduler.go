@@ -0,0 +1,45 @@
+package game
+
+// Every character collects Speed action points per turn and may act once it has actionReady of them,
+// each action then costs some points. A rat with speed 2 therefore gets two moves for every step of the
+// player, a character that just picked something up is due again sooner than one that fought.
+const (
+	actionReady = 1.0
+	// action points are handed out in small ticks so characters of different speeds interleave fairly
+	tick = 1.0 / 8
+
+	MoveCost   = 1.0
+	AttackCost = 1.0
+	DoorCost   = 1.0
+	LeverCost  = 1.0
+	WaitCost   = 1.0
+	PickupCost = 0.5
+	DropCost   = 0.5
+	EquipCost  = 1.0
+	UseCost    = 1.0
+	SearchCost = 1.0
+)
+
+// advanceTime hands out action points until the player is due again, every monster on the
+// player's level acts as often as it can afford on the way
+func (game *Game) advanceTime() {
+	level := game.CurrentLevel
+	player := level.Player
+	playerSpeed := player.Stats().Speed
+	if playerSpeed <= 0 {
+		player.ActionPoints = actionReady // a frozen player would stall the game forever
+	}
+	for player.ActionPoints < actionReady && player.Hitpoints > 0 {
+		player.ActionPoints += playerSpeed * tick
+		for _, monster := range level.SortedMonsters() {
+			monster.ActionPoints += monster.Stats().Speed * tick
+			// a monster may have been killed by the one acting before it
+			for level.Monsters[monster.Pos] == monster && monster.ActionPoints >= actionReady && player.Hitpoints > 0 {
+				// monsters go after whoever of the party is closest
+				level.Player = level.nearestPlayer(monster.Pos)
+				monster.ActionPoints -= monster.Update(level)
+			}
+		}
+	}
+	level.Player = player
+}
@@ -0,0 +1,118 @@
+package game
+
+// Snapshot is what a remote viewer needs to draw the level its player is on. Unlike a *Level it
+// holds no pointers into the running game, so it can be encoded and sent over the network.
+type Snapshot struct {
+	Level     string
+	Map       [][]Tile
+	Player    Player
+	Others    []Player // the rest of the party
+	Monsters  []saveMonster
+	Items     map[Pos][]*Item
+	Traps     []Trap // the ones found so far
+	LastEvent GameEvent
+	Depth     int
+	Messages  []Message // logged since the log had seen total messages, see Game.Snapshot
+	LogTotal  int
+}
+
+// Snapshot copies the current level as p sees it, with the messages logged since the viewer's
+// log had seen total of them. A player who died sees the game over screen.
+func (game *Game) Snapshot(p *Player, seen int) *Snapshot {
+	level := game.CurrentLevel
+	s := &Snapshot{
+		Level:     level.Name,
+		Map:       make([][]Tile, len(level.Map)),
+		Player:    p.clone(),
+		Items:     make(map[Pos][]*Item),
+		LastEvent: level.LastEvent,
+		Depth:     level.Depth,
+		Messages:  append([]Message(nil), game.Log.Since(seen)...),
+		LogTotal:  game.Log.Total,
+	}
+	for y, row := range level.Map {
+		s.Map[y] = append([]Tile(nil), row...)
+	}
+	for _, other := range game.Players {
+		if other != p {
+			s.Others = append(s.Others, other.clone())
+		}
+	}
+	for _, m := range level.Monsters {
+		s.Monsters = append(s.Monsters, saveMonster{m.Character.clone(), m.State, m.Post, m.LastSeen, m.XP})
+	}
+	for pos, items := range level.Items {
+		s.Items[pos] = copyItems(items)
+	}
+	for _, trap := range level.Traps {
+		if trap.Found {
+			s.Traps = append(s.Traps, *trap)
+		}
+	}
+	if p.Hitpoints <= 0 {
+		s.LastEvent = GameOver
+	}
+	return s
+}
+
+func copyItems(items []*Item) []*Item {
+	copies := make([]*Item, len(items))
+	for i, item := range items {
+		copies[i] = copyItem(item)
+	}
+	return copies
+}
+
+func copyItem(item *Item) *Item {
+	if item == nil {
+		return nil
+	}
+	c := *item
+	return &c
+}
+
+// clone copies the character with its backpack and equipment, nothing is shared with the original
+func (c *Character) clone() Character {
+	copied := *c
+	copied.Items = copyItems(c.Items)
+	for _, slot := range []**Item{&copied.Helmet, &copied.Weapon, &copied.Armor, &copied.Shield, &copied.Boots, &copied.LeftRing, &copied.RightRing, &copied.Amulet} {
+		*slot = copyItem(*slot)
+	}
+	return copied
+}
+
+func (p *Player) clone() Player {
+	copied := *p
+	copied.Character = p.Character.clone()
+	copied.Path = append([]Pos(nil), p.Path...)
+	return copied
+}
+
+// NewLevel rebuilds a level to draw from the snapshot, the new messages are added to log
+func (s *Snapshot) NewLevel(defs *Definitions, log *MessageLog) *Level {
+	for _, msg := range s.Messages {
+		log.Add(msg)
+	}
+	level := &Level{Name: s.Level, Map: s.Map, LastEvent: s.LastEvent, Depth: s.Depth, Log: log}
+	level.Debug = make(map[Pos]bool)
+	level.Portals = make(map[Pos]*LevelPos)
+	level.Items = s.Items
+	level.Traps = make(map[Pos]*Trap)
+	for i := range s.Traps {
+		level.Traps[s.Traps[i].Pos] = &s.Traps[i]
+	}
+	player := s.Player
+	level.Player = &player
+	level.Players = []*Player{level.Player}
+	for i := range s.Others {
+		level.Players = append(level.Players, &s.Others[i])
+	}
+	level.Monsters = make(map[Pos]*Monster)
+	for _, sm := range s.Monsters {
+		monster := &Monster{Character: sm.Character, State: sm.State, Post: sm.Post, LastSeen: sm.LastSeen, XP: sm.XP}
+		monster.Behaviour = defs.behaviour(monster.Name)
+		level.Monsters[monster.Pos] = monster
+	}
+	level.index()
+	return level
+}
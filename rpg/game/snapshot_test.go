@@ -0,0 +1,30 @@
+package game
+
+import "testing"
+
+func TestSnapshotIsACopy(t *testing.T) {
+	game, err := NewGame(0, Options{Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := game.Players[0]
+	sword := &Item{Typ: Weapon, Entity: Entity{Name: "Sword"}, Mods: Stats{Attack: 2}}
+	p.Weapon = sword
+	p.Items = append(p.Items, &Item{Typ: Consumable, Entity: Entity{Name: "Potion"}, Count: 1})
+	p.Path = []Pos{{1, 1}}
+
+	s := game.Snapshot(p, 0)
+	sword.Mods.Attack = 5
+	p.Items[0].Count = 3
+	p.Path[0] = Pos{2, 2}
+
+	if s.Player.Weapon == sword || s.Player.Weapon.Mods.Attack != 2 {
+		t.Error("the snapshot shares the wielded weapon with the game")
+	}
+	if s.Player.Items[0].Count != 1 {
+		t.Error("the snapshot shares the backpack with the game")
+	}
+	if s.Player.Path[0] != (Pos{1, 1}) {
+		t.Error("the snapshot shares the travel path with the game")
+	}
+}
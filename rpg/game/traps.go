@@ -0,0 +1,174 @@
+package game
+
+import (
+	"sort"
+	"strconv"
+)
+
+// Trap is a hidden feature of a level that goes off whenever a player steps on it, set up by the level
+// script. Searching next to it can find it first, found traps are drawn and travel walks around them.
+type Trap struct {
+	Pos
+	Kind  string `json:"kind"`  // "dart", "pit" (drops the player to the level below) or "alarm" (wakes up the level)
+	Power int    `json:"power"` // hitpoints a dart or the fall takes
+	Found bool   `json:"found"` // shown from the start, or since a player found it
+}
+
+// TrapRune is what a found trap is drawn as
+const TrapRune = '^'
+
+var trapKinds = map[string]bool{"dart": true, "pit": true, "alarm": true}
+
+// the chance of finding something hidden next to the player with each search
+const (
+	searchChance = 0.25 // with no sight range at experience level 1
+	searchSight  = 0.02 // added for every point of sight range
+	searchLevel  = 0.05 // added for every experience level after the first
+	searchMax    = 0.95
+)
+
+// checkTrap tells what is wrong with a trap of the level script, nothing if it can be set up
+func (level *Level) checkTrap(trap *Trap) string {
+	if !inRange(level, trap.Pos) {
+		return trap.Pos.String() + " is outside of the map"
+	}
+	tile := level.Map[trap.Y][trap.X]
+	switch {
+	case !trapKinds[trap.Kind]:
+		return "unknown kind " + trap.Kind
+	case level.Traps[trap.Pos] != nil:
+		return "another trap is at " + trap.Pos.String()
+	case tile.Rune == StoneWall || tile.Rune == Blank || tile.OverlayRune != Blank:
+		return "no floor for a trap at " + trap.Pos.String()
+	case trap.Power < 0:
+		return "negative power"
+	}
+	return ""
+}
+
+// spring sets off the trap under the acting player
+func (game *Game) spring(trap *Trap) {
+	level := game.CurrentLevel
+	p := level.Player
+	trap.Found = true
+	switch trap.Kind {
+	case "dart":
+		level.AddMessage(Combat, "A dart shoots out of the wall and hits "+p.Name+" for "+strconv.Itoa(trap.Power))
+		trap.hurt(p)
+	case "alarm":
+		level.AddMessage(General, p.Name+" stepped on an alarm, the whole level is awake")
+		for _, m := range level.SortedMonsters() {
+			m.Disturb(level)
+		}
+	case "pit":
+		level.AddMessage(General, p.Name+" fell into a pit")
+		trap.hurt(p)
+		below := game.below(level)
+		if below == nil || p.Hitpoints <= 0 {
+			return // a shallow pit
+		}
+		free := below.freeTiles()
+		if len(free) == 0 {
+			return
+		}
+		game.CurrentLevel = below
+		p.Pos = free[below.random().Intn(len(free))]
+		game.gatherParty(p)
+		below.AddMessage(General, p.Name+" landed on "+below.Name)
+		below.lineOfSight()
+	}
+}
+
+func (trap *Trap) hurt(p *Player) {
+	p.Hitpoints -= trap.Power
+	if p.Hitpoints <= 0 {
+		p.KilledBy = "a " + trap.Kind + " trap"
+	}
+}
+
+// below finds the level the down stairs of level lead to, the game's generator makes one if they
+// don't lead anywhere yet. Levels without down stairs have nothing below them.
+func (game *Game) below(level *Level) *Level {
+	for y, row := range level.Map {
+		for x, tile := range row {
+			if tile.OverlayRune != DownStair {
+				continue
+			}
+			pos := Pos{x, y}
+			if portal := level.Portals[pos]; portal != nil {
+				return portal.Level
+			}
+			if game.options.Generator == nil {
+				continue
+			}
+			if portal := game.generateBelow(level, pos); portal != nil {
+				return portal.Level
+			}
+		}
+	}
+	return nil
+}
+
+// search looks for hidden traps and secret doors next to the acting player, each of them is found
+// with a chance that grows with the player's sight range and experience level
+func (game *Game) search() float64 {
+	level := game.CurrentLevel
+	p := level.Player
+	chance := searchChance + searchSight*float64(p.Stats().SightRange) + searchLevel*float64(p.ExpLevel-1)
+	if chance > searchMax {
+		chance = searchMax
+	}
+	found := false
+	for _, step := range steps {
+		pos := p.Pos.Add(step)
+		if level.Secrets[pos] && level.random().Float64() < chance {
+			level.Secrets[pos] = false
+			level.Map[pos.Y][pos.X] = Tile{Rune: level.bfsFloor(pos), OverlayRune: ClosedDoor, Visible: true, Seen: true}
+			level.AddMessage(Door, p.Name+" found a secret door")
+			found = true
+		}
+		if trap := level.Traps[pos]; trap != nil && !trap.Found && level.random().Float64() < chance {
+			trap.Found = true
+			level.AddMessage(General, p.Name+" found a "+trap.Kind+" trap")
+			found = true
+		}
+	}
+	if !found {
+		level.AddMessage(General, p.Name+" searched and found nothing")
+	}
+	level.lineOfSight()
+	return SearchCost
+}
+
+// FoundTrap returns the trap at pos if it is known, for drawing it
+func (level *Level) FoundTrap(pos Pos) *Trap {
+	if trap := level.Traps[pos]; trap != nil && trap.Found {
+		return trap
+	}
+	return nil
+}
+
+// sortedTraps and sortedSecrets list the level's traps and hidden doors in reading order, for the state hash
+func (level *Level) sortedTraps() []*Trap {
+	traps := make([]*Trap, 0, len(level.Traps))
+	for _, trap := range level.Traps {
+		traps = append(traps, trap)
+	}
+	sort.Slice(traps, func(i, j int) bool {
+		return traps[i].Pos.Less(traps[j].Pos)
+	})
+	return traps
+}
+
+func (level *Level) sortedSecrets() []Pos {
+	secrets := make([]Pos, 0, len(level.Secrets))
+	for pos, hidden := range level.Secrets {
+		if hidden {
+			secrets = append(secrets, pos)
+		}
+	}
+	sort.Slice(secrets, func(i, j int) bool {
+		return secrets[i].Less(secrets[j])
+	})
+	return secrets
+}
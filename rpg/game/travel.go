@@ -0,0 +1,148 @@
+package game
+
+// startTravel sets the player off towards a seen tile, the first step is taken at once
+func (game *Game) startTravel(to Pos) float64 {
+	level := game.CurrentLevel
+	p := level.Player
+	if to == p.Pos {
+		return 0
+	}
+	var path []Pos
+	if travelWalkable(level, to) {
+		path = level.astarWith(p.Pos, to, travelNeighbors)
+	}
+	if len(path) < 2 {
+		level.AddEvent("Can't find a way there")
+		return 0
+	}
+	p.Path = path[1:]
+	game.spotted = level.visibleMonsters()
+	return game.travelStep()
+}
+
+func (game *Game) stopTravel() {
+	p := game.CurrentLevel.Player
+	p.Path = nil
+	p.Exploring = false
+	game.spotted = nil
+}
+
+// travelStep takes the player one step along its path and returns the cost, the journey ends
+// early when a new monster comes into view or something blocks the way
+func (game *Game) travelStep() float64 {
+	level := game.CurrentLevel
+	p := level.Player
+	if game.spotMonster() {
+		return 0
+	}
+	if len(p.Path) == 0 && p.Exploring {
+		target, found := level.nearestUnexplored(p.Pos)
+		if !found {
+			level.AddEvent("Nothing left to explore")
+			game.stopTravel()
+			return 0
+		}
+		path := level.astarWith(p.Pos, target, travelNeighbors)
+		if len(path) < 2 {
+			level.AddEvent("Can't find a way to explore further")
+			game.stopTravel()
+			return 0
+		}
+		p.Path = path[1:]
+	}
+	if len(p.Path) == 0 {
+		game.stopTravel()
+		return 0
+	}
+
+	next := p.Path[0]
+	if _, exists := level.Monsters[next]; exists || level.PlayerAt(next) != nil || !travelWalkable(level, next) {
+		game.stopTravel()
+		return 0
+	}
+	cost := game.resolveMovement(next)
+	if cost == 0 || game.CurrentLevel != level {
+		// bumped into something or went through a portal
+		game.stopTravel()
+		return cost
+	}
+	if p.Pos == next {
+		p.Path = p.Path[1:] // opening a door doesn't move the player, the next step walks through it
+	}
+	if !p.Exploring && len(p.Path) == 0 {
+		game.stopTravel()
+	}
+	game.spotMonster()
+	return cost
+}
+
+// spotMonster stops the travel when a monster the player didn't see when setting off is in view
+func (game *Game) spotMonster() bool {
+	level := game.CurrentLevel
+	for monster := range level.visibleMonsters() {
+		if !game.spotted[monster] {
+			level.AddEvent(level.Player.Name + " spotted a " + monster.Name)
+			game.stopTravel()
+			return true
+		}
+	}
+	return false
+}
+
+func (level *Level) visibleMonsters() map[*Monster]bool {
+	visible := make(map[*Monster]bool)
+	for pos, monster := range level.Monsters {
+		if level.Map[pos.Y][pos.X].Visible {
+			visible[monster] = true
+		}
+	}
+	return visible
+}
+
+// travelWalkable is what the player knows to be walkable, closed doors included as walking into them opens them
+func travelWalkable(level *Level, pos Pos) bool {
+	if !inRange(level, pos) || !level.Map[pos.Y][pos.X].Seen {
+		return false
+	}
+	switch level.Map[pos.Y][pos.X].Rune {
+	case StoneWall, Blank:
+		return false
+	}
+	return level.FoundTrap(pos) == nil
+}
+
+// travelNeighbors ignores monsters, they will have moved by the time the player gets there
+func travelNeighbors(level *Level, pos Pos) []Pos {
+	neighbors := make([]Pos, 0, 8)
+	for _, step := range steps {
+		next := pos.Add(step)
+		if travelWalkable(level, next) && level.canStep(pos, next) {
+			neighbors = append(neighbors, next)
+		}
+	}
+	return neighbors
+}
+
+// nearestUnexplored searches breadth-first for the closest reachable tile next to one the player hasn't seen
+func (level *Level) nearestUnexplored(start Pos) (Pos, bool) {
+	frontier := []Pos{start}
+	visited := map[Pos]bool{start: true}
+	for len(frontier) > 0 {
+		current := frontier[0]
+		frontier = frontier[1:]
+		if current != start {
+			for _, step := range steps {
+				if next := current.Add(step); inRange(level, next) && !level.Map[next.Y][next.X].Seen {
+					return current, true
+				}
+			}
+		}
+		for _, next := range travelNeighbors(level, current) {
+			if !visited[next] {
+				frontier = append(frontier, next)
+				visited[next] = true
+			}
+		}
+	}
+	return Pos{}, false
+}
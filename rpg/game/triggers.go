@@ -0,0 +1,331 @@
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+)
+
+// levelScript is the optional <level>.triggers.json next to a map, it locks doors and sets up the
+// pressure plates, levers and traps of the level, see rpg/game/maps/level2.triggers.json
+type levelScript struct {
+	Locks    []*Lock    `json:"locks"`
+	Triggers []*Trigger `json:"triggers"`
+	Traps    []*Trap    `json:"traps"`
+}
+
+// Lock keeps the closed door at its position shut for anyone who doesn't carry the key
+type Lock struct {
+	Pos            // "x" and "y" in the script
+	Key     string `json:"key"`     // name of the item that opens it, without one only a trigger can
+	Consume bool   `json:"consume"` // the key is used up opening the door
+}
+
+// Trigger carries out its actions when a player steps on its plate or pulls its lever
+type Trigger struct {
+	Pos
+	Name    string   `json:"name"` // only used in error messages
+	Kind    string   `json:"kind"` // "plate" (the default) or "lever"
+	Once    bool     `json:"once"` // fires only the first time
+	Actions []Action `json:"actions"`
+	Fired   int      `json:"-"` // how often it went off
+}
+
+// Action is one thing a trigger does when it fires
+type Action struct {
+	Pos
+	Do      string `json:"do"`      // "open", "close", "toggle" or "unlock" the door at x,y, "spawn" a monster there or show a "message"
+	Monster string `json:"monster"` // name of the monster to spawn
+	Text    string `json:"text"`
+}
+
+const (
+	plateTrigger = "plate"
+	leverTrigger = "lever"
+)
+
+// ScriptError points at the lock or trigger of a level script that is wrong
+type ScriptError struct {
+	File  string
+	Kind  string // "lock", "trigger" or "trap", empty if the whole file is wrong
+	Index int    // 1-based position in its list
+	Name  string
+	Msg   string
+}
+
+func (e *ScriptError) Error() string {
+	msg := e.File
+	if e.Kind != "" {
+		msg += fmt.Sprintf(": %s %d", e.Kind, e.Index)
+	}
+	if e.Name != "" {
+		msg += fmt.Sprintf(" %q", e.Name)
+	}
+	return msg + ": " + e.Msg
+}
+
+// loadScript reads the level's script if there is one, the locks and triggers that make sense are set up
+// even if others are wrong
+func loadScript(maps fs.FS, level *Level, defs *Definitions) []error {
+	filename := level.Name + ".triggers.json"
+	file, err := maps.Open(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return []error{err}
+	}
+	defer file.Close()
+	return level.readScript(filename, file, defs)
+}
+
+func (level *Level) readScript(filename string, r io.Reader, defs *Definitions) []error {
+	var script levelScript
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&script); err != nil {
+		return []error{&ScriptError{File: filename, Msg: err.Error()}}
+	}
+
+	var errs []error
+	if level.Locks == nil {
+		level.Locks = make(map[Pos]*Lock)
+	}
+	for i, lock := range script.Locks {
+		fail := func(msg string) {
+			errs = append(errs, &ScriptError{filename, "lock", i + 1, "", msg})
+		}
+		switch {
+		case !inRange(level, lock.Pos):
+			fail(lock.Pos.String() + " is outside of the map")
+		case level.Map[lock.Y][lock.X].OverlayRune != ClosedDoor:
+			fail("no closed door at " + lock.Pos.String())
+		case level.Locks[lock.Pos] != nil:
+			fail("door at " + lock.Pos.String() + " is already locked")
+		case lock.Key != "" && defs.Item(lock.Key) == nil:
+			fail("unknown key " + lock.Key)
+		default:
+			level.Locks[lock.Pos] = lock
+		}
+	}
+
+	if level.Triggers == nil {
+		level.Triggers = make(map[Pos]*Trigger)
+	}
+	for i, t := range script.Triggers {
+		fail := func(msg string) {
+			errs = append(errs, &ScriptError{filename, "trigger", i + 1, t.Name, msg})
+		}
+		if t.Kind == "" {
+			t.Kind = plateTrigger
+		}
+		if !inRange(level, t.Pos) {
+			fail(t.Pos.String() + " is outside of the map")
+			continue
+		}
+		tile := level.Map[t.Y][t.X]
+		switch {
+		case t.Kind != plateTrigger && t.Kind != leverTrigger:
+			fail("unknown kind " + t.Kind)
+		case level.Triggers[t.Pos] != nil:
+			fail("another trigger is at " + t.Pos.String())
+		case t.Kind == plateTrigger && (tile.Rune == StoneWall || tile.Rune == Blank):
+			fail("plate in a wall at " + t.Pos.String())
+		case t.Kind == leverTrigger && (tile.Rune == Blank || tile.OverlayRune != Blank):
+			fail("no room for a lever at " + t.Pos.String())
+		case len(t.Actions) == 0:
+			fail("does nothing")
+		default:
+			valid := true
+			for j, action := range t.Actions {
+				if msg := level.checkAction(action, defs); msg != "" {
+					fail(fmt.Sprintf("action %d: %s", j+1, msg))
+					valid = false
+				}
+			}
+			if !valid {
+				continue
+			}
+			if t.Kind == leverTrigger {
+				level.Map[t.Y][t.X].OverlayRune = Lever
+			}
+			level.Triggers[t.Pos] = t
+		}
+	}
+
+	if level.Traps == nil {
+		level.Traps = make(map[Pos]*Trap)
+	}
+	for i, trap := range script.Traps {
+		if msg := level.checkTrap(trap); msg != "" {
+			errs = append(errs, &ScriptError{filename, "trap", i + 1, "", msg})
+			continue
+		}
+		level.Traps[trap.Pos] = trap
+	}
+	return errs
+}
+
+// checkAction tells what is wrong with an action, nothing if it can be carried out
+func (level *Level) checkAction(action Action, defs *Definitions) string {
+	if action.Do == "message" {
+		if action.Text == "" {
+			return "no text"
+		}
+		return ""
+	}
+	if !inRange(level, action.Pos) {
+		return action.Pos.String() + " is outside of the map"
+	}
+	tile := level.Map[action.Y][action.X]
+	switch action.Do {
+	case "open", "close", "toggle", "unlock":
+		if tile.OverlayRune != ClosedDoor && tile.OverlayRune != OpenDoor {
+			return "no door at " + action.Pos.String()
+		}
+	case "spawn":
+		if defs.Monster(action.Monster) == nil {
+			return "unknown monster " + action.Monster
+		}
+		if tile.Rune == StoneWall || tile.Rune == Blank {
+			return "spawn in a wall at " + action.Pos.String()
+		}
+	default:
+		return "unknown action " + action.Do
+	}
+	return ""
+}
+
+// unlock tries the acting player's keys on the door at pos and tells whether it can be opened,
+// doors without a lock always can
+func (level *Level) unlock(pos Pos) bool {
+	lock := level.Locks[pos]
+	if lock == nil {
+		return true
+	}
+	p := level.Player
+	for i, item := range p.Items {
+		if lock.Key == "" || item.Name != lock.Key {
+			continue
+		}
+		if lock.Consume {
+			p.Items = append(p.Items[:i], p.Items[i+1:]...)
+		}
+		delete(level.Locks, pos)
+		level.AddMessage(Door, p.Name+" unlocked a door with the "+item.Name, p.ID, item.ID)
+		return true
+	}
+	level.AddMessage(Door, "The door is locked")
+	return false
+}
+
+// stepOn fires the pressure plate and springs the trap at pos, if there are any
+func (game *Game) stepOn(pos Pos) {
+	level := game.CurrentLevel
+	if t := level.Triggers[pos]; t != nil && t.Kind == plateTrigger {
+		game.fire(t)
+	}
+	if trap := level.Traps[pos]; trap != nil {
+		game.spring(trap) // last, a pit leaves the level
+	}
+}
+
+// pull fires the lever at pos and returns what pulling it cost, false if there is no lever to pull
+func (game *Game) pull(pos Pos) (float64, bool) {
+	level := game.CurrentLevel
+	t := level.Triggers[pos]
+	if t == nil || t.Kind != leverTrigger {
+		return 0, false
+	}
+	if t.spent() {
+		level.AddMessage(General, "The lever doesn't budge")
+		return 0, true
+	}
+	level.AddMessage(General, level.Player.Name+" pulled a lever")
+	game.fire(t)
+	return LeverCost, true
+}
+
+// spent tells whether the trigger only fires once and already has
+func (t *Trigger) spent() bool {
+	return t.Once && t.Fired > 0
+}
+
+// fire carries out the trigger's actions, a spent trigger does nothing
+func (game *Game) fire(t *Trigger) {
+	if t.spent() {
+		return
+	}
+	t.Fired++
+	level := game.CurrentLevel
+	for _, action := range t.Actions {
+		if action.Do == "message" {
+			level.AddMessage(General, action.Text)
+			continue
+		}
+		tile := &level.Map[action.Y][action.X]
+		switch action.Do {
+		case "open":
+			delete(level.Locks, action.Pos)
+			level.openDoor(tile)
+		case "close":
+			level.closeDoor(tile, action.Pos)
+		case "toggle":
+			if tile.OverlayRune == OpenDoor {
+				level.closeDoor(tile, action.Pos)
+			} else {
+				delete(level.Locks, action.Pos)
+				level.openDoor(tile)
+			}
+		case "unlock":
+			delete(level.Locks, action.Pos)
+		case "spawn":
+			// a monster or player in the way keeps the monster from showing up
+			if canWalk(level, action.Pos) && level.PlayerAt(action.Pos) == nil {
+				level.AddMonster(game.Defs.Monster(action.Monster).New(action.Pos, level.random()))
+			}
+		}
+	}
+	level.lineOfSight()
+}
+
+func (level *Level) openDoor(tile *Tile) {
+	if tile.OverlayRune == ClosedDoor {
+		tile.OverlayRune = OpenDoor
+		level.LastEvent = DoorOpen
+	}
+}
+
+// closeDoor shuts the door unless something is standing or lying in the doorway
+func (level *Level) closeDoor(tile *Tile, pos Pos) {
+	if tile.OverlayRune != OpenDoor || level.Monsters[pos] != nil || level.PlayerAt(pos) != nil || len(level.Items[pos]) > 0 {
+		return
+	}
+	tile.OverlayRune = ClosedDoor
+}
+
+// sortedLocks and sortedTriggers list the level's locks and triggers in reading order, for the state hash
+func (level *Level) sortedLocks() []*Lock {
+	locks := make([]*Lock, 0, len(level.Locks))
+	for _, lock := range level.Locks {
+		locks = append(locks, lock)
+	}
+	sort.Slice(locks, func(i, j int) bool {
+		return locks[i].Pos.Less(locks[j].Pos)
+	})
+	return locks
+}
+
+func (level *Level) sortedTriggers() []*Trigger {
+	triggers := make([]*Trigger, 0, len(level.Triggers))
+	for _, t := range level.Triggers {
+		triggers = append(triggers, t)
+	}
+	sort.Slice(triggers, func(i, j int) bool {
+		return triggers[i].Pos.Less(triggers[j].Pos)
+	})
+	return triggers
+}
@@ -0,0 +1,139 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// MapError points at the line and column of a map file the loader couldn't make sense of
+type MapError struct {
+	File   string
+	Line   int // 1-based, 0 if the whole file is wrong
+	Column int // 1-based, counted in runes
+	Rune   rune
+	Msg    string
+}
+
+func (e *MapError) Error() string {
+	msg := e.File
+	if e.Line > 0 {
+		msg += fmt.Sprintf(":%d:%d", e.Line, e.Column)
+	}
+	msg += ": " + e.Msg
+	if e.Rune != 0 {
+		msg += fmt.Sprintf(" %q", e.Rune)
+	}
+	return msg
+}
+
+// WorldError points at the row and CSV field of the world file that is wrong
+type WorldError struct {
+	File  string
+	Line  int // 1-based, 0 if the whole file is wrong
+	Field int // 1-based, 0 if the whole row is wrong
+	Value string
+	Msg   string
+}
+
+func (e *WorldError) Error() string {
+	msg := e.File
+	if e.Line > 0 {
+		msg += fmt.Sprintf(":%d", e.Line)
+	}
+	if e.Field > 0 {
+		msg += fmt.Sprintf(": field %d %q", e.Field, e.Value)
+	}
+	return msg + ": " + e.Msg
+}
+
+// Validate loads the definitions, every map with its script and the world file and reports all problems it finds instead of
+// stopping at the first one, on top of what the loaders check it also reports portals starting or landing on walls
+func Validate(opts Options) []error {
+	maps := opts.maps()
+	worldFile := opts.worldFile()
+	// without the definitions every monster and item would be reported as an invalid character
+	defs, errs := opts.readDefinitions()
+	if len(errs) > 0 {
+		return errs
+	}
+	levels := make(map[string]*Level)
+
+	filenames, err := fs.Glob(maps, "*.map")
+	if err != nil {
+		return []error{err}
+	}
+	if len(filenames) == 0 {
+		errs = append(errs, fmt.Errorf("no *.map files found"))
+	}
+	var starts []string
+	for _, filename := range filenames {
+		levelName := strings.TrimSuffix(path.Base(filename), ".map")
+		file, err := maps.Open(filename)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		level, err := LoadLevel(levelName, file, defs)
+		file.Close()
+		if err != nil {
+			errs = append(errs, err)
+			levels[levelName] = nil // known but broken, portals to it are not reported again
+			continue
+		}
+		errs = append(errs, loadScript(maps, level, defs)...)
+		if level.playerStart != nil {
+			starts = append(starts, filename)
+		}
+		levels[levelName] = level
+	}
+	if len(starts) == 0 && len(errs) == 0 {
+		errs = append(errs, fmt.Errorf("no level has a player start '@'"))
+	}
+	if len(starts) > 1 {
+		errs = append(errs, fmt.Errorf("more than one player start '@': %s", strings.Join(starts, ", ")))
+	}
+
+	file, err := maps.Open(worldFile)
+	if err != nil {
+		return append(errs, err)
+	}
+	defer file.Close()
+	startRow, portals, err := readWorld(file)
+	if err != nil {
+		var worldErr *WorldError
+		if errors.As(err, &worldErr) {
+			worldErr.File = worldFile
+		}
+		return append(errs, err)
+	}
+
+	if _, exists := levels[startRow.name]; !exists {
+		errs = append(errs, &WorldError{worldFile, startRow.line, 1, startRow.name, "unknown level"})
+	}
+	for _, portal := range portals {
+		errs = append(errs, checkPortalEnd(worldFile, portal.line, 1, portal.from, portal.fromPos, levels)...)
+		errs = append(errs, checkPortalEnd(worldFile, portal.line, 4, portal.to, portal.toPos, levels)...)
+	}
+	return errs
+}
+
+func checkPortalEnd(worldFile string, line, field int, levelName string, pos Pos, levels map[string]*Level) []error {
+	level, exists := levels[levelName]
+	if !exists {
+		return []error{&WorldError{worldFile, line, field, levelName, "unknown level"}}
+	}
+	if level == nil {
+		return nil
+	}
+	if !inRange(level, pos) {
+		return []error{&WorldError{worldFile, line, field + 1, pos.String(), "outside of level " + levelName}}
+	}
+	switch level.Map[pos.Y][pos.X].Rune {
+	case StoneWall, Blank:
+		return []error{&WorldError{worldFile, line, field + 1, pos.String(), "portal in a wall on level " + levelName}}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package game
+
+// Viewers are the windows or other front ends watching a game. Each one has a level channel with
+// room for one level, after every input the game offers it a copy of the current level without
+// waiting, so a slow viewer just skips the levels it had no time to look at. Viewers join and
+// leave through the input channel, so only the game loop sends on or closes a level channel.
+
+// Join asks the running game for a new viewer and returns its level channel, spectators only watch,
+// the game ignores their inputs apart from CloseWindow. Don't call it from the game loop itself.
+func (game *Game) Join(spectator bool) chan *Level {
+	levelChan := make(chan *Level, 1)
+	typ := JoinGame
+	if spectator {
+		typ = Spectate
+	}
+	game.InputChan <- &Input{Typ: typ, LevelChannel: levelChan}
+	return levelChan
+}
+
+// Leave detaches a viewer, its level channel is closed once the game gets to it
+func (game *Game) Leave(levelChan chan *Level) {
+	game.InputChan <- &Input{Typ: CloseWindow, LevelChannel: levelChan}
+}
+
+func (game *Game) addViewer(levelChan chan *Level, spectator bool) {
+	if levelChan == nil {
+		return
+	}
+	if cap(levelChan) == 0 {
+		close(levelChan) // the game would have to wait for it, refuse it instead
+		return
+	}
+	for _, c := range game.LevelChans {
+		if c == levelChan {
+			return
+		}
+	}
+	game.LevelChans = append(game.LevelChans, levelChan)
+	if spectator {
+		if game.spectators == nil {
+			game.spectators = make(map[chan *Level]bool)
+		}
+		game.spectators[levelChan] = true
+	}
+}
+
+func (game *Game) removeViewer(levelChan chan *Level) {
+	for i, c := range game.LevelChans {
+		if c == levelChan {
+			game.LevelChans = append(game.LevelChans[:i], game.LevelChans[i+1:]...)
+			delete(game.spectators, levelChan)
+			close(levelChan)
+			return
+		}
+	}
+}
+
+func (game *Game) closeViewers() {
+	for _, c := range game.LevelChans {
+		close(c)
+	}
+	game.LevelChans = nil
+	game.spectators = nil
+}
+
+func (game *Game) broadcast() {
+	if len(game.LevelChans) == 0 {
+		return
+	}
+	view := game.view()
+	for _, c := range game.LevelChans {
+		offer(c, view)
+	}
+}
+
+// view copies the current level as the acting player sees it, built from a Snapshot like the
+// levels of network clients
+func (game *Game) view() *Level {
+	log := *game.Log // Add only appends or starts a new slice, the messages the copy holds stay as they are
+	return game.Snapshot(game.CurrentLevel.Player, log.Total).NewLevel(game.Defs, &log)
+}
+
+// offer hands level to a viewer without waiting for it, a viewer that hasn't picked up the
+// previous level gets this one instead
+func offer(levelChan chan *Level, level *Level) {
+	select {
+	case levelChan <- level:
+	default:
+		select {
+		case <-levelChan:
+		default:
+		}
+		levelChan <- level
+	}
+}
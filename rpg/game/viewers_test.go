@@ -0,0 +1,117 @@
+package game
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestViewersGetCopies(t *testing.T) {
+	game, err := NewGame(1, Options{Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	levelChan := game.LevelChans[0]
+	go game.Run()
+	defer func() { game.InputChan <- &Input{Typ: QuitGame} }()
+
+	view := <-levelChan
+	for _, typ := range []InputType{Left, Left, Up} {
+		pos := view.Player.Pos
+		game.InputChan <- &Input{Typ: typ}
+		next := <-levelChan
+		if view.Player.Pos != pos {
+			t.Fatalf("the game moved the player of a level it had already sent, %v is now %v", pos, view.Player.Pos)
+		}
+		if next == view || next.Player == view.Player {
+			t.Fatal("the game sent the same level twice")
+		}
+		view = next
+	}
+}
+
+// watch reads levels off levelChan like a window would, the returned channel is closed once the
+// game closed levelChan
+func watch(levelChan chan *Level) chan struct{} {
+	closed := make(chan struct{})
+	go func() {
+		for range levelChan {
+		}
+		close(closed)
+	}()
+	return closed
+}
+
+// within fails the test if c isn't closed in time, the game is stuck then
+func within(t *testing.T, c chan struct{}, what string) bool {
+	t.Helper()
+	select {
+	case <-c:
+		return true
+	case <-time.After(5 * time.Second):
+		t.Errorf("timed out waiting until %s", what)
+		return false
+	}
+}
+
+func TestViewersJoinAndLeave(t *testing.T) {
+	game, err := NewGame(1, Options{Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	window := game.LevelChans[0]
+	done := make(chan struct{})
+	go func() {
+		game.Run()
+		close(done)
+	}()
+	watch(window)
+
+	// viewers come and go while the player moves, spectators leave by quitting
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(spectator bool) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				levelChan := game.Join(spectator)
+				closed := watch(levelChan)
+				if spectator {
+					game.InputChan <- &Input{Typ: Left, LevelChannel: levelChan} // ignored
+					game.InputChan <- &Input{Typ: QuitGame, LevelChannel: levelChan}
+				} else {
+					game.Leave(levelChan)
+				}
+				if !within(t, closed, "a viewer that left is let go") {
+					return
+				}
+			}
+		}(i%2 == 0)
+	}
+	moves := make(chan struct{})
+	go func() {
+		for i := 0; i < 40; i++ {
+			game.InputChan <- &Input{Typ: []InputType{Left, Right}[i%2]}
+		}
+		close(moves)
+	}()
+	churn := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(churn)
+	}()
+	if !within(t, churn, "the viewers are done") || !within(t, moves, "the moves are done") {
+		t.FailNow()
+	}
+	select {
+	case <-done:
+		t.Fatal("a spectator quitting ended the game")
+	default:
+	}
+
+	// the last window going away ends the game, the spectators still watching are told
+	spectator := watch(game.Join(true))
+	game.Leave(window)
+	within(t, done, "the game ends without a player")
+	within(t, spectator, "the spectator is told the game ended")
+}
@@ -0,0 +1,184 @@
+// Package headless drives a game.Game without a window, feeding it inputs
+// and checking the level once the game has handled them.
+package headless
+
+import (
+	"fmt"
+	"gameswithgo/rpg/game"
+)
+
+type Driver struct {
+	Game *game.Game
+	// Level is the last level the game sent over the level channel, the same copy a window would
+	// draw, so the checks only see what a front end gets to see
+	Level     *game.Level
+	levelChan chan *game.Level
+	done      chan bool
+}
+
+// NewDriver starts the game loop and waits for the first level snapshot.
+// The game must have been created with exactly one level channel.
+func NewDriver(g *game.Game) *Driver {
+	d := &Driver{Game: g, levelChan: g.LevelChans[0], done: make(chan bool)}
+	go func() {
+		g.Run()
+		close(d.done)
+	}()
+	d.Level = <-d.levelChan
+	return d
+}
+
+// Send feeds one input to the game and returns the level it sends back
+func (d *Driver) Send(input *game.Input) *game.Level {
+	d.Game.InputChan <- input
+	d.Level = <-d.levelChan
+	return d.Level
+}
+
+// Travel sends a TravelTo or Explore input and keeps the player going until it stops,
+// maxTravelSteps guards against a journey that never ends
+func (d *Driver) Travel(input *game.Input) *game.Level {
+	d.Send(input)
+	for i := 0; i < maxTravelSteps && (len(d.Level.Player.Path) > 0 || d.Level.Player.Exploring); i++ {
+		d.SendType(game.Travel)
+	}
+	return d.Level
+}
+
+const maxTravelSteps = 1000
+
+func (d *Driver) SendType(typ game.InputType) *game.Level {
+	return d.Send(&game.Input{Typ: typ})
+}
+
+// Quit stops the game loop and waits for it to return
+func (d *Driver) Quit() {
+	d.Game.InputChan <- &game.Input{Typ: game.QuitGame}
+	<-d.done
+}
+
+func (d *Driver) ExpectPlayerAt(pos game.Pos) error {
+	if d.Level.Player.Pos != pos {
+		return fmt.Errorf("player at %v, want %v", d.Level.Player.Pos, pos)
+	}
+	return nil
+}
+
+func (d *Driver) ExpectHitpoints(hp int) error {
+	if d.Level.Player.Hitpoints != hp {
+		return fmt.Errorf("player has %d hitpoints, want %d", d.Level.Player.Hitpoints, hp)
+	}
+	return nil
+}
+
+// ExpectMonster checks for a monster called name at pos, an empty name checks that the tile is free
+func (d *Driver) ExpectMonster(pos game.Pos, name string) error {
+	monster, exists := d.Level.Monsters[pos]
+	switch {
+	case name == "" && exists:
+		return fmt.Errorf("found %s at %v, want no monster", monster.Name, pos)
+	case name != "" && !exists:
+		return fmt.Errorf("no monster at %v, want %s", pos, name)
+	case name != "" && monster.Name != name:
+		return fmt.Errorf("found %s at %v, want %s", monster.Name, pos, name)
+	}
+	return nil
+}
+
+// ExpectItem checks whether an item called name lies on the ground at pos
+func (d *Driver) ExpectItem(pos game.Pos, name string, want bool) error {
+	found := findItem(d.Level.Items[pos], name) != nil
+	if found != want {
+		return fmt.Errorf("item %s on the ground at %v: %v, want %v", name, pos, found, want)
+	}
+	return nil
+}
+
+// ExpectFound checks whether the trap or secret door at pos has been found. Only the game knows
+// what is still hidden, the rest is checked on the level it sent: a found secret door is drawn as a
+// door instead of a wall and a found trap is sent along with the level.
+func (d *Driver) ExpectFound(pos game.Pos, want bool) error {
+	var found bool
+	live := d.Game.CurrentLevel // the game waits for the next input, it can be read
+	_, secret := live.Secrets[pos]
+	switch {
+	case secret:
+		found = d.Level.Map[pos.Y][pos.X].Rune != game.StoneWall
+	case live.Traps[pos] != nil:
+		found = d.Level.FoundTrap(pos) != nil
+	default:
+		return fmt.Errorf("nothing hidden at %v", pos)
+	}
+	if found != want {
+		return fmt.Errorf("found at %v: %v, want %v", pos, found, want)
+	}
+	return nil
+}
+
+// ExpectCarrying checks whether the player has an item called name in the backpack
+func (d *Driver) ExpectCarrying(name string, want bool) error {
+	found := findItem(d.Level.Player.Items, name) != nil
+	if found != want {
+		return fmt.Errorf("player carrying %s: %v, want %v", name, found, want)
+	}
+	return nil
+}
+
+// ExpectCount checks how many of a consumable called name the player has on its stack
+func (d *Driver) ExpectCount(name string, n int) error {
+	item := findItem(d.Level.Player.Items, name)
+	got := 0
+	if item != nil {
+		got = item.Count
+	}
+	if got != n {
+		return fmt.Errorf("player carrying %d %s, want %d", got, name, n)
+	}
+	return nil
+}
+
+// ExpectEquipped checks one of the player's equipment slots, an empty name checks that the slot is empty
+func (d *Driver) ExpectEquipped(slot string, name string) error {
+	for _, s := range equipmentSlots(&d.Level.Player.Character) {
+		if s.name != slot {
+			continue
+		}
+		got := ""
+		if s.item != nil {
+			got = s.item.Name
+		}
+		if got != name {
+			return fmt.Errorf("%s slot holds %q, want %q", slot, got, name)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown equipment slot %s", slot)
+}
+
+type equipmentSlot struct {
+	name string
+	item *game.Item
+}
+
+// equipmentSlots names the character's slots the way scripts refer to them
+func equipmentSlots(c *game.Character) []equipmentSlot {
+	return []equipmentSlot{
+		{"helmet", c.Helmet},
+		{"weapon", c.Weapon},
+		{"armor", c.Armor},
+		{"shield", c.Shield},
+		{"boots", c.Boots},
+		{"ring1", c.LeftRing},
+		{"ring2", c.RightRing},
+		{"amulet", c.Amulet},
+	}
+}
+
+func findItem(items []*game.Item, name string) *game.Item {
+	for _, item := range items {
+		if item.Name == name {
+			return item
+		}
+	}
+	return nil
+}
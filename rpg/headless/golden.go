@@ -0,0 +1,87 @@
+package headless
+
+import (
+	"bytes"
+	"fmt"
+	"gameswithgo/rpg/game"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// GoldenResult is how a script played by RunGolden went, Err is nil if it matched its golden file
+type GoldenResult struct {
+	Script string
+	Err    error
+}
+
+// RunGolden plays every *.script in dir against a fresh game made with the script's options and
+// compares the transcript with the matching *.golden file, or rewrites the golden files when update is set.
+// The error is only set if the scripts couldn't be found, how each of them went is in the results.
+func RunGolden(dir string, newGame func(game.Options) (*game.Game, error), update bool) ([]GoldenResult, error) {
+	scripts, err := filepath.Glob(filepath.Join(dir, "*.script"))
+	if err != nil {
+		return nil, err
+	}
+	if len(scripts) == 0 {
+		return nil, fmt.Errorf("no scripts in %s", dir)
+	}
+
+	results := make([]GoldenResult, len(scripts))
+	for i, scriptName := range scripts {
+		results[i] = GoldenResult{scriptName, runGoldenScript(scriptName, newGame, update)}
+	}
+	return results, nil
+}
+
+func runGoldenScript(scriptName string, newGame func(game.Options) (*game.Game, error), update bool) error {
+	script, err := ioutil.ReadFile(scriptName)
+	if err != nil {
+		return err
+	}
+	opts, err := ScriptOptions(bytes.NewReader(script))
+	if err != nil {
+		return err
+	}
+
+	g, err := newGame(opts)
+	if err != nil {
+		return err
+	}
+	var transcript bytes.Buffer
+	err = RunScript(g, bytes.NewReader(script), &transcript)
+	if err != nil {
+		return err
+	}
+
+	goldenName := strings.TrimSuffix(scriptName, ".script") + ".golden"
+	if update {
+		return ioutil.WriteFile(goldenName, transcript.Bytes(), 0644)
+	}
+	golden, err := ioutil.ReadFile(goldenName)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(golden, transcript.Bytes()) {
+		return fmt.Errorf("transcript differs from %s:\n%s", goldenName, firstDiff(string(golden), transcript.String()))
+	}
+	return nil
+}
+
+func firstDiff(want, got string) string {
+	wantLines := strings.Split(want, "\n")
+	gotLines := strings.Split(got, "\n")
+	for i := 0; i < len(wantLines) || i < len(gotLines); i++ {
+		var w, g string
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if w != g {
+			return fmt.Sprintf("line %d\n  want: %s\n  got:  %s", i+1, w, g)
+		}
+	}
+	return ""
+}
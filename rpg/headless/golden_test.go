@@ -0,0 +1,29 @@
+package headless
+
+import (
+	"flag"
+	"gameswithgo/rpg/game"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files instead of comparing against them")
+
+func TestGolden(t *testing.T) {
+	scripts, err := filepath.Glob(filepath.Join("testdata", "*.script"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scripts) == 0 {
+		t.Fatal("no scripts in testdata")
+	}
+	newGame := func(opts game.Options) (*game.Game, error) { return game.NewGame(1, opts) }
+	for _, script := range scripts {
+		script := script
+		t.Run(filepath.Base(script), func(t *testing.T) {
+			if err := runGoldenScript(script, newGame, *update); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
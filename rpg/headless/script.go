@@ -0,0 +1,393 @@
+package headless
+
+import (
+	"bufio"
+	"fmt"
+	"gameswithgo/rpg/game"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Scripts are plain text, one command per line, '#' starts a comment:
+//
+//	options <option>...       set up the game, only before the first command, see ScriptOptions
+//	up, down, left, right     move or attack in that direction
+//	upleft, upright, downleft, downright
+//	take                      take everything on the player's tile
+//	search                    look for hidden traps and secret doors next to the player
+//	travel <x>,<y>            walk there until arriving or interrupted
+//	explore                   walk to unexplored places until interrupted or done
+//	take|drop|equip|use <item>  act on the first item with that name, names may contain spaces
+//	restart                   restart after dying
+//	expect player <x>,<y>
+//	expect hp <n>
+//	expect level <name>       the player is on the level of that name
+//	expect dead
+//	expect xp <n> [level]
+//	expect monster <x>,<y> [name]  (no name: tile must be free of monsters)
+//	expect item|noitem <x>,<y> <name>
+//	expect found|hidden <x>,<y>  the trap or secret door there was found or is still hidden
+//	expect carrying|notcarrying <name>
+//	expect count <n> <name>   the player carries a stack of n
+//	expect equipped <slot> [name]  slot is helmet, weapon, armor, shield, boots, ring1, ring2 or amulet
+//	expect stats <attack> <defense> <speed> <sight>
+var moves = map[string]game.InputType{
+	"up":        game.Up,
+	"down":      game.Down,
+	"left":      game.Left,
+	"right":     game.Right,
+	"upleft":    game.UpLeft,
+	"upright":   game.UpRight,
+	"downleft":  game.DownLeft,
+	"downright": game.DownRight,
+}
+
+type ScriptError struct {
+	Line int
+	Err  error
+}
+
+func (e *ScriptError) Error() string {
+	return "line " + strconv.Itoa(e.Line) + ": " + e.Err.Error()
+}
+
+// ScriptOptions reads the options lines a script starts with into the Options to create its game with:
+//
+//	strictcorners             no diagonal steps past the corner of a wall
+//	seed=<n>                  seeds the dice
+func ScriptOptions(script io.Reader) (game.Options, error) {
+	var opts game.Options
+	scanner := bufio.NewScanner(script)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		fields := scriptFields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] != "options" {
+			break
+		}
+		for _, option := range fields[1:] {
+			name, value := option, ""
+			if i := strings.Index(option, "="); i >= 0 {
+				name, value = option[:i], option[i+1:]
+			}
+			var err error
+			switch name {
+			case "strictcorners":
+				opts.StrictCorners = true
+			case "seed":
+				opts.Seed, err = strconv.ParseInt(value, 10, 64)
+			default:
+				err = fmt.Errorf("unknown option %s", name)
+			}
+			if err != nil {
+				return opts, &ScriptError{lineNo, err}
+			}
+		}
+	}
+	return opts, scanner.Err()
+}
+
+// scriptFields splits a script line into its words, leaving out the comment
+func scriptFields(line string) []string {
+	if i := strings.Index(line, "#"); i >= 0 {
+		line = line[:i]
+	}
+	return strings.Fields(line)
+}
+
+// RunScript plays the script against g and writes a transcript of the game state after
+// every command to transcript, it stops at the first failed expectation. The game must
+// have been created with the script's options, see ScriptOptions.
+func RunScript(g *game.Game, script io.Reader, transcript io.Writer) error {
+	d := NewDriver(g)
+	defer d.Quit()
+
+	seen := d.Level.Log.Total
+	writeState(transcript, d, &seen)
+
+	scanner := bufio.NewScanner(script)
+	lineNo := 0
+	started := false
+	for scanner.Scan() {
+		lineNo++
+		fields := scriptFields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		var err error
+		if fields[0] == "options" {
+			if started {
+				err = fmt.Errorf("options must come before the first command")
+			}
+		} else if fields[0] == "expect" {
+			err = d.expect(fields[1:])
+		} else {
+			err = d.command(fields)
+			if err == nil {
+				fmt.Fprintln(transcript, ">", strings.Join(fields, " "))
+				writeState(transcript, d, &seen)
+			}
+		}
+		if err != nil {
+			return &ScriptError{lineNo, err}
+		}
+		started = started || fields[0] != "options"
+	}
+	return scanner.Err()
+}
+
+func (d *Driver) command(fields []string) error {
+	if typ, ok := moves[fields[0]]; ok && len(fields) == 1 {
+		d.SendType(typ)
+		return nil
+	}
+
+	if fields[0] == "take" && len(fields) == 1 {
+		d.SendType(game.TakeAll)
+		return nil
+	}
+
+	if fields[0] == "search" && len(fields) == 1 {
+		d.SendType(game.Search)
+		return nil
+	}
+
+	if fields[0] == "restart" && len(fields) == 1 {
+		d.SendType(game.Restart)
+		return nil
+	}
+
+	if fields[0] == "explore" && len(fields) == 1 {
+		d.Travel(&game.Input{Typ: game.Explore})
+		return nil
+	}
+
+	if len(fields) < 2 {
+		return fmt.Errorf("can't understand %q", strings.Join(fields, " "))
+	}
+	name := strings.Join(fields[1:], " ")
+	switch fields[0] {
+	case "travel":
+		pos, err := parsePos(name)
+		if err != nil {
+			return err
+		}
+		d.Travel(&game.Input{Typ: game.TravelTo, Pos: pos})
+	case "take":
+		item := findItem(d.Level.Items[d.Level.Player.Pos], name)
+		if item == nil {
+			return fmt.Errorf("no %s on the ground at %v", name, d.Level.Player.Pos)
+		}
+		d.Send(&game.Input{Typ: game.TakeItem, ItemID: item.ID})
+	case "drop", "equip", "use":
+		item := findItem(d.Level.Player.Items, name)
+		if item == nil {
+			return fmt.Errorf("player isn't carrying %s", name)
+		}
+		typ := game.DropItem
+		switch fields[0] {
+		case "equip":
+			typ = game.EquipItem
+		case "use":
+			typ = game.UseItem
+		}
+		d.Send(&game.Input{Typ: typ, ItemID: item.ID})
+	default:
+		return fmt.Errorf("unknown command %s", fields[0])
+	}
+	return nil
+}
+
+func (d *Driver) expect(fields []string) error {
+	if len(fields) == 0 {
+		return fmt.Errorf("expect what?")
+	}
+	args := fields[1:]
+	switch fields[0] {
+	case "player":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: expect player <x>,<y>")
+		}
+		pos, err := parsePos(args[0])
+		if err != nil {
+			return err
+		}
+		return d.ExpectPlayerAt(pos)
+	case "hp":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: expect hp <n>")
+		}
+		hp, err := strconv.Atoi(args[0])
+		if err != nil {
+			return err
+		}
+		return d.ExpectHitpoints(hp)
+	case "level":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: expect level <name>")
+		}
+		return d.ExpectLevel(args[0])
+	case "xp":
+		if len(args) < 1 || len(args) > 2 {
+			return fmt.Errorf("usage: expect xp <n> [level]")
+		}
+		xp, err := strconv.Atoi(args[0])
+		if err != nil {
+			return err
+		}
+		expLevel := d.Level.Player.ExpLevel
+		if len(args) == 2 {
+			if expLevel, err = strconv.Atoi(args[1]); err != nil {
+				return err
+			}
+		}
+		p := d.Level.Player
+		if p.XP != xp || p.ExpLevel != expLevel {
+			return fmt.Errorf("player has %d xp at level %d, want %d at level %d", p.XP, p.ExpLevel, xp, expLevel)
+		}
+		return nil
+	case "dead":
+		if d.Level.LastEvent != game.GameOver {
+			return fmt.Errorf("player is alive with %d hitpoints", d.Level.Player.Hitpoints)
+		}
+		return nil
+	case "monster":
+		if len(args) < 1 || len(args) > 2 {
+			return fmt.Errorf("usage: expect monster <x>,<y> [name]")
+		}
+		pos, err := parsePos(args[0])
+		if err != nil {
+			return err
+		}
+		name := ""
+		if len(args) == 2 {
+			name = args[1]
+		}
+		return d.ExpectMonster(pos, name)
+	case "item", "noitem":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: expect %s <x>,<y> <name>", fields[0])
+		}
+		pos, err := parsePos(args[0])
+		if err != nil {
+			return err
+		}
+		return d.ExpectItem(pos, strings.Join(args[1:], " "), fields[0] == "item")
+	case "found", "hidden":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: expect %s <x>,<y>", fields[0])
+		}
+		pos, err := parsePos(args[0])
+		if err != nil {
+			return err
+		}
+		return d.ExpectFound(pos, fields[0] == "found")
+	case "carrying", "notcarrying":
+		if len(args) < 1 {
+			return fmt.Errorf("usage: expect %s <name>", fields[0])
+		}
+		return d.ExpectCarrying(strings.Join(args, " "), fields[0] == "carrying")
+	case "count":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: expect count <n> <name>")
+		}
+		n, err := strconv.Atoi(args[0])
+		if err != nil {
+			return err
+		}
+		return d.ExpectCount(strings.Join(args[1:], " "), n)
+	case "equipped":
+		if len(args) < 1 {
+			return fmt.Errorf("usage: expect equipped <slot> [name]")
+		}
+		return d.ExpectEquipped(args[0], strings.Join(args[1:], " "))
+	case "stats":
+		if len(args) != 4 {
+			return fmt.Errorf("usage: expect stats <attack> <defense> <speed> <sight>")
+		}
+		got := d.Level.Player.Stats()
+		if want := strings.Join(args, " "); statsString(got) != want {
+			return fmt.Errorf("player stats are %s, want %s", statsString(got), want)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown expectation %s", fields[0])
+}
+
+func (d *Driver) ExpectLevel(name string) error {
+	got := d.LevelName()
+	if got != name {
+		return fmt.Errorf("player is on level %s, want %s", got, name)
+	}
+	return nil
+}
+
+func (d *Driver) LevelName() string {
+	return d.Level.Name
+}
+
+func parsePos(s string) (game.Pos, error) {
+	xy := strings.Split(s, ",")
+	if len(xy) != 2 {
+		return game.Pos{}, fmt.Errorf("bad position %q, want x,y", s)
+	}
+	x, err := strconv.Atoi(xy[0])
+	if err != nil {
+		return game.Pos{}, err
+	}
+	y, err := strconv.Atoi(xy[1])
+	if err != nil {
+		return game.Pos{}, err
+	}
+	return game.Pos{X: x, Y: y}, nil
+}
+
+// statsString writes stats the way expect stats takes them: attack defense speed sight
+func statsString(stats game.Stats) string {
+	return fmt.Sprintf("%d %d %g %d", stats.Attack, stats.Defense, stats.Speed, stats.SightRange)
+}
+
+func itemNames(items []*game.Item) string {
+	names := make([]string, len(items))
+	for i, item := range items {
+		names[i] = item.Name
+		if item.Count > 1 {
+			names[i] += " x" + strconv.Itoa(item.Count)
+		}
+	}
+	return "[" + strings.Join(names, ", ") + "]"
+}
+
+// writeState prints the bits of the level a script can observe, plus any events logged since the last call
+// seen is how many log messages were already written, it is moved past the new ones
+func writeState(w io.Writer, d *Driver, seen *int) {
+	level := d.Level
+	p := level.Player
+	fmt.Fprintf(w, "  level %s, player (%d,%d) hp %d, turn %d, xp %d (level %d)\n", d.LevelName(), p.X, p.Y, p.Hitpoints, p.Turns, p.XP, p.ExpLevel)
+	fmt.Fprintf(w, "  carrying %s", itemNames(p.Items))
+	for _, slot := range equipmentSlots(&p.Character) {
+		if slot.item != nil {
+			fmt.Fprintf(w, ", %s %s", slot.name, slot.item.Name)
+		}
+	}
+	fmt.Fprintln(w)
+	if len(p.Equipment()) > 0 {
+		fmt.Fprintf(w, "  stats %s\n", statsString(p.Stats()))
+	}
+	if items := level.Items[p.Pos]; len(items) > 0 {
+		fmt.Fprintf(w, "  on the ground %s\n", itemNames(items))
+	}
+	for _, monster := range level.SortedMonsters() {
+		fmt.Fprintf(w, "  %s (%d,%d) hp %d\n", monster.Name, monster.X, monster.Y, monster.Hitpoints)
+	}
+
+	for _, msg := range level.Log.Since(*seen) {
+		fmt.Fprintf(w, "  event: %s\n", msg.Text)
+	}
+	*seen = level.Log.Total
+}
@@ -1,29 +1,89 @@
 package main
 
 import (
+	"flag"
+	"fmt"
+	"gameswithgo/rpg/cli"
 	"gameswithgo/rpg/game"
+	"gameswithgo/rpg/netplay"
 	"gameswithgo/rpg/ui2d"
+	"io/fs"
+	"net"
+	"os"
 )
 
 func main() {
-	//numWindows := 1 // does not work with > 1
-	//
-	//rpgGame := game.NewGame(numWindows, "rpg/game/maps/level1.map")
-	//
-	//for i := 0; i < numWindows; i++ {
-	//	go func(i int) {
-	//		runtime.LockOSThread() // OSes require main app thread to stay associated with one system thread
-	//		ui := ui2d.NewUI(rpgGame.InputChan, rpgGame.LevelChans[i])
-	//		ui.Run()
-	//	}(i)
-	//}
-	//
-	//rpgGame.Run()
+	flags := cli.Register("address the server listens on or the client connects to")
+	assetDir := flag.String("assets", "", "directory with the tiles, fonts and sounds (default: built-in assets)")
+	spectators := flag.Int("spectators", 0, "number of extra windows that watch the player, F2 opens more while playing")
+	flag.Parse()
+
+	opts := flags.Options()
+	switch flag.Arg(0) {
+	case "validate":
+		validate(opts)
+		return
+	case "server":
+		serve(flags, opts)
+		return
+	}
+
+	var assets fs.FS
+	if *assetDir != "" {
+		assets = os.DirFS(*assetDir)
+	}
+	if flag.Arg(0) == "client" {
+		client, defs := flags.Join(opts)
+		ui2d.NewUI(client.InputChan, client.LevelChan, assets, defs).Run()
+		return
+	}
 
 	//rpg := game.NewGame(1, "rpg/game/maps/level1.map")
-	rpg := game.NewGame(1)
+	rpg := flags.NewGame(1, opts)
+	printStart(flags)
+	flags.StartReplay(rpg)
 
 	go func() { rpg.Run() }()
-	ui := ui2d.NewUI(rpg.InputChan, rpg.LevelChans[0])
+	// all windows run on the main thread, SDL wants its events handled there
+	ui := ui2d.NewUI(rpg.InputChan, rpg.LevelChans[0], assets, rpg.Defs)
+	for i := 0; i < *spectators; i++ {
+		ui.AddSpectator(rpg.Join(true))
+	}
 	ui.Run()
 }
+
+// printStart tells the seed of the dungeon and where the game is recorded to
+func printStart(flags *cli.Flags) {
+	fmt.Println("dungeon seed:", flags.Seed)
+	if flags.Record != "" {
+		fmt.Println("recording to", flags.Record)
+	}
+}
+
+// validate checks the definitions, every map and the world file without starting the game:
+// rpg [-maps dir] [-world file] [-defs file] validate
+func validate(opts game.Options) {
+	errs := game.Validate(opts)
+	for _, err := range errs {
+		fmt.Println(err)
+	}
+	if len(errs) > 0 {
+		os.Exit(1)
+	}
+	fmt.Println("maps ok")
+}
+
+// serve runs the game for players joining over the network, without a window of its own:
+// rpg [-addr host:port] server
+func serve(flags *cli.Flags, opts game.Options) {
+	rpg := flags.NewGame(0, opts)
+	printStart(flags)
+	ln, err := net.Listen("tcp", flags.Addr)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	fmt.Println("listening on", ln.Addr())
+	fmt.Println(netplay.NewServer(rpg).Serve(ln))
+	os.Exit(1)
+}
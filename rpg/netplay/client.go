@@ -0,0 +1,127 @@
+package netplay
+
+import (
+	"encoding/gob"
+	"errors"
+	"gameswithgo/rpg/game"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Client plays a game running on a server. To the ui it looks like a local game: it reads the
+// inputs from InputChan and hands out the levels the server sends on LevelChan, which is closed
+// when the connection goes away.
+type Client struct {
+	InputChan chan *game.Input
+	LevelChan chan *game.Level
+	LogPath   string
+
+	conn net.Conn
+	name string
+	defs *game.Definitions
+
+	mu  sync.Mutex
+	log *game.MessageLog
+}
+
+// Dial connects to the server at addr and joins the game as name, defs should be the
+// definitions the server uses
+func Dial(addr, name string, defs *game.Definitions) (*Client, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{InputChan: make(chan *game.Input), LevelChan: make(chan *game.Level, 1), LogPath: "rpg-log.txt",
+		conn: conn, name: name, defs: defs, log: &game.MessageLog{}}
+	return c, nil
+}
+
+// Run passes inputs and levels back and forth until the ui quits or the server hangs up
+func (c *Client) Run() error {
+	defer close(c.LevelChan)
+	enc := gob.NewEncoder(c.conn)
+	if err := enc.Encode(hello{c.name}); err != nil {
+		c.conn.Close()
+		return err
+	}
+	go c.send(enc)
+
+	dec := gob.NewDecoder(c.conn)
+	for {
+		var snapshot game.Snapshot
+		if err := dec.Decode(&snapshot); err != nil {
+			c.conn.Close()
+			if errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		c.mu.Lock()
+		level := snapshot.NewLevel(c.defs, c.log)
+		// the ui reads the log while the next snapshot is added to it, so every level gets its own copy
+		log := *c.log
+		log.Messages = append([]game.Message(nil), c.log.Messages...)
+		level.Log = &log
+		c.mu.Unlock()
+		// only the newest level matters to the ui
+		select {
+		case c.LevelChan <- level:
+		default:
+			select {
+			case <-c.LevelChan:
+			default:
+			}
+			c.LevelChan <- level
+		}
+	}
+}
+
+func (c *Client) send(enc *gob.Encoder) {
+	for input := range c.InputChan {
+		if input == nil {
+			continue
+		}
+		switch input.Typ {
+		case game.JoinGame, game.Spectate:
+			close(input.LevelChannel) // spectators can only watch a local game
+			continue
+		case game.ExportLog:
+			if err := c.exportLog(); err != nil {
+				c.addMessage("Exporting the log failed: " + err.Error())
+			} else {
+				c.addMessage("Log exported to " + c.LogPath)
+			}
+			continue
+		}
+		req := request{Typ: input.Typ, Pos: input.Pos, ItemID: input.ItemID, Slot: input.Slot}
+		if err := enc.Encode(req); err != nil || input.Typ == game.QuitGame || input.Typ == game.CloseWindow {
+			c.conn.Close() // ends Run, which closes LevelChan
+			return
+		}
+	}
+}
+
+// exportLog writes the messages this client has seen next to it, not on the server
+func (c *Client) exportLog() error {
+	file, err := os.Create(c.LogPath)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	err = c.log.Export(file)
+	c.mu.Unlock()
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// addMessage logs something only this client needs to know, it shows up with the next level
+func (c *Client) addMessage(text string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.log.Add(game.Message{Time: time.Now(), Kind: game.System, Text: text})
+}
@@ -0,0 +1,22 @@
+// Package netplay lets several people play one game over TCP. The server owns the game, the
+// clients send it their inputs and get back a snapshot of the level after every turn:
+//
+//	client -> server: hello, then a request per input
+//	server -> client: a *game.Snapshot per turn
+//
+// Everything is gob encoded.
+package netplay
+
+import "gameswithgo/rpg/game"
+
+type hello struct {
+	Name string
+}
+
+// request is a game.Input without the channel and the player, the server knows who sent it
+type request struct {
+	Typ    game.InputType
+	Pos    game.Pos
+	ItemID game.EntityID
+	Slot   game.Slot
+}
@@ -0,0 +1,214 @@
+package netplay
+
+import (
+	"encoding/gob"
+	"fmt"
+	"gameswithgo/rpg/game"
+	"net"
+	"time"
+)
+
+// outgoing snapshots a client may fall behind by before it is dropped
+const sendBuffer = 64
+
+const helloTimeout = 10 * time.Second
+
+// Server runs a game for the clients connected to it. Only the goroutine started by Serve touches
+// the game, the connections just pass requests in and snapshots out.
+type Server struct {
+	game    *game.Game
+	joins   chan *client
+	leaves  chan *client
+	inputs  chan clientRequest
+	clients []*client
+}
+
+type client struct {
+	conn   net.Conn
+	name   string
+	player *game.Player
+	seen   int // log messages the client has been sent
+	out    chan *game.Snapshot
+}
+
+type clientRequest struct {
+	client *client
+	req    request
+}
+
+func NewServer(g *game.Game) *Server {
+	return &Server{game: g, joins: make(chan *client), leaves: make(chan *client), inputs: make(chan clientRequest)}
+}
+
+// Serve accepts players on ln until it fails. The first one takes over the player from the maps,
+// everyone after that joins the party as a new character.
+func (s *Server) Serve(ln net.Listener) error {
+	go s.run()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		go s.handshake(conn)
+	}
+}
+
+func (s *Server) handshake(conn net.Conn) {
+	dec := gob.NewDecoder(conn)
+	var h hello
+	conn.SetReadDeadline(time.Now().Add(helloTimeout))
+	if err := dec.Decode(&h); err != nil {
+		fmt.Println("netplay:", conn.RemoteAddr(), err)
+		conn.Close()
+		return
+	}
+	conn.SetReadDeadline(time.Time{})
+	if h.Name == "" {
+		h.Name = "Player"
+	}
+	c := &client{conn: conn, name: h.Name, out: make(chan *game.Snapshot, sendBuffer)}
+	go c.write()
+	s.joins <- c
+	for {
+		var req request
+		if err := dec.Decode(&req); err != nil {
+			s.leaves <- c
+			return
+		}
+		s.inputs <- clientRequest{c, req}
+	}
+}
+
+// write sends the snapshots until the server closes out, a failed send closes the connection
+// so the reading side notices too
+func (c *client) write() {
+	enc := gob.NewEncoder(c.conn)
+	for snapshot := range c.out {
+		if err := enc.Encode(snapshot); err != nil {
+			c.conn.Close()
+			for range c.out {
+			}
+			return
+		}
+	}
+}
+
+func (s *Server) run() {
+	for {
+		select {
+		case c := <-s.joins:
+			s.clients = append(s.clients, c)
+			s.bind(c)
+			fmt.Println("netplay:", c.name, "joined from", c.conn.RemoteAddr())
+		case c := <-s.leaves:
+			s.drop(c)
+		case in := <-s.inputs:
+			if !s.connected(in.client) {
+				continue
+			}
+			s.handle(in.client, in.req)
+		}
+		s.broadcast()
+	}
+}
+
+func (s *Server) handle(c *client, req request) {
+	g := s.game
+	switch req.Typ {
+	case game.QuitGame, game.CloseWindow:
+		s.drop(c)
+		return
+	case game.SaveGame, game.LoadGame, game.ExportLog:
+		// the files are the server's, loading would also take the players away from their clients
+		g.CurrentLevel.AddMessage(game.System, "Only the server can save, load and export the log")
+		return
+	case game.JoinGame, game.Spectate:
+		return
+	case game.Restart:
+		if g.Dead {
+			g.Handle(&game.Input{Typ: game.Restart})
+			s.rebind()
+		} else if c.player.Hitpoints <= 0 {
+			c.player = g.AddPlayer(c.name) // back in as a new character while the others play on
+		}
+		return
+	}
+
+	g.Handle(&game.Input{Typ: req.Typ, Pos: req.Pos, ItemID: req.ItemID, Slot: req.Slot, Player: c.player})
+}
+
+// bind gives c a player from the party nobody controls or, failing that, a new one
+func (s *Server) bind(c *client) {
+	g := s.game
+	for _, p := range g.Players {
+		if !s.claimed(p) {
+			c.player = p
+			p.Name = c.name
+			return
+		}
+	}
+	c.player = g.AddPlayer(c.name)
+}
+
+func (s *Server) claimed(p *game.Player) bool {
+	for _, c := range s.clients {
+		if c.player == p {
+			return true
+		}
+	}
+	return false
+}
+
+// rebind hands out the players of a new game
+func (s *Server) rebind() {
+	for _, c := range s.clients {
+		c.player = nil
+	}
+	for _, c := range s.clients {
+		s.bind(c)
+	}
+}
+
+func (s *Server) connected(c *client) bool {
+	for _, other := range s.clients {
+		if other == c {
+			return true
+		}
+	}
+	return false
+}
+
+// drop disconnects c, its character leaves the game unless it is the last one, which waits for the next client
+func (s *Server) drop(c *client) {
+	if !s.connected(c) {
+		return
+	}
+	clients := s.clients[:0]
+	for _, other := range s.clients {
+		if other != c {
+			clients = append(clients, other)
+		}
+	}
+	s.clients = clients
+	close(c.out)
+	c.conn.Close()
+
+	g := s.game
+	if g.InParty(c.player) && len(g.Players) > 1 {
+		g.RemovePlayer(c.player)
+		g.CurrentLevel.AddMessage(game.General, c.name+" left the game")
+	}
+	fmt.Println("netplay:", c.name, "left")
+}
+
+func (s *Server) broadcast() {
+	for _, c := range append([]*client(nil), s.clients...) {
+		snapshot := s.game.Snapshot(c.player, c.seen)
+		c.seen = snapshot.LogTotal
+		select {
+		case c.out <- snapshot:
+		default:
+			s.drop(c) // too far behind to catch up
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package netplay
+
+import (
+	"gameswithgo/rpg/game"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// waitFor reads levels from c until one satisfies cond
+func waitFor(t *testing.T, c *Client, what string, cond func(*game.Level) bool) *game.Level {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case level, ok := <-c.LevelChan:
+			if !ok {
+				t.Fatalf("%s: connection closed while waiting until %s", c.name, what)
+			}
+			if cond(level) {
+				return level
+			}
+		case <-timeout:
+			t.Fatalf("%s: timed out waiting until %s", c.name, what)
+		}
+	}
+}
+
+// other finds the party member called name in what the level shows
+func other(level *game.Level, name string) *game.Player {
+	for _, p := range level.Players {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// away is the step leading from pos away from from
+func away(pos, from game.Pos) game.InputType {
+	if pos.X >= from.X {
+		return game.Right
+	}
+	return game.Left
+}
+
+func TestLoopback(t *testing.T) {
+	g, err := game.NewGame(0, game.Options{Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.SavePath = filepath.Join(t.TempDir(), "rpg.sav")
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go NewServer(g).Serve(ln)
+
+	dial := func(name string) *Client {
+		c, err := Dial(ln.Addr().String(), name, game.DefaultDefinitions())
+		if err != nil {
+			t.Fatal(err)
+		}
+		go c.Run()
+		t.Cleanup(func() { c.conn.Close() })
+		return c
+	}
+	ann := dial("Ann")
+	waitFor(t, ann, "Ann is in the game", func(l *game.Level) bool { return l.Player.Name == "Ann" })
+	bob := dial("Bob")
+	level := waitFor(t, bob, "Bob joined Ann", func(l *game.Level) bool { return len(l.Players) == 2 })
+
+	// both step away from each other, so neither is in the other's way
+	bobPos, annPos := level.Player.Pos, other(level, "Ann").Pos
+	bob.InputChan <- &game.Input{Typ: away(bobPos, annPos)}
+	waitFor(t, bob, "Bob moved", func(l *game.Level) bool { return l.Player.Turns == 1 })
+	ann.InputChan <- &game.Input{Typ: away(annPos, bobPos)}
+
+	annView := waitFor(t, ann, "Ann moved and sees Bob's move", func(l *game.Level) bool {
+		b := other(l, "Bob")
+		return l.Player.Turns == 1 && b != nil && b.Turns == 1
+	})
+	bobView := waitFor(t, bob, "Bob sees Ann's move", func(l *game.Level) bool {
+		a := other(l, "Ann")
+		return a != nil && a.Turns == 1
+	})
+	if got, want := other(annView, "Bob").Pos, bobView.Player.Pos; got != want {
+		t.Errorf("Ann sees Bob at %v, Bob is at %v", got, want)
+	}
+	if got, want := other(bobView, "Ann").Pos, annView.Player.Pos; got != want {
+		t.Errorf("Bob sees Ann at %v, Ann is at %v", got, want)
+	}
+
+	// the save file is the server's business
+	bob.InputChan <- &game.Input{Typ: game.SaveGame}
+	waitFor(t, bob, "saving was refused", func(l *game.Level) bool {
+		last := l.Log.Last(1)
+		return len(last) == 1 && last[0].Kind == game.System
+	})
+	if _, err := os.Stat(g.SavePath); !os.IsNotExist(err) {
+		t.Error("a client saved the server's game")
+	}
+
+	ann.InputChan <- &game.Input{Typ: game.QuitGame}
+	waitFor(t, bob, "Ann left the party", func(l *game.Level) bool { return len(l.Players) == 1 })
+}
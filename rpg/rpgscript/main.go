@@ -0,0 +1,68 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"gameswithgo/rpg/game"
+	"gameswithgo/rpg/game/gen"
+	"gameswithgo/rpg/headless"
+	"os"
+)
+
+// Plays the scripted sessions in rpg/headless/testdata without opening a window
+// against the built-in maps, run from the repo root: go run ./rpg/rpgscript [-update] [dir]
+// or checks that a recording made with rpg -record still plays out the same: go run ./rpg/rpgscript -replay file
+func main() {
+	update := flag.Bool("update", false, "rewrite the golden files instead of comparing against them")
+	replay := flag.String("replay", "", "replay this recording and check it against the recorded states instead")
+	flag.Parse()
+
+	if *replay != "" {
+		err := checkReplay(*replay)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Println("ok  ", *replay)
+		return
+	}
+
+	dir := "rpg/headless/testdata"
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+
+	newGame := func(opts game.Options) (*game.Game, error) { return game.NewGame(1, opts) }
+	results, err := headless.RunGolden(dir, newGame, *update)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	failed := 0
+	for _, result := range results {
+		if result.Err != nil {
+			fmt.Println("FAIL", result.Script+":", result.Err)
+			failed++
+		} else {
+			fmt.Println("ok  ", result.Script)
+		}
+	}
+	if failed > 0 {
+		fmt.Printf("%d of %d scripts failed\n", failed, len(results))
+		os.Exit(1)
+	}
+}
+
+func checkReplay(path string) error {
+	rec, err := game.ReadRecordingFile(path)
+	if err != nil {
+		return err
+	}
+	opts := rec.Options()
+	opts.Generator = gen.New(rec.Seed)
+	g, err := game.NewGame(0, opts)
+	if err != nil {
+		return err
+	}
+	return g.Replay(rec)
+}
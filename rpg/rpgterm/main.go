@@ -0,0 +1,28 @@
+package main
+
+import (
+	"flag"
+	"gameswithgo/rpg/cli"
+	"gameswithgo/rpg/uiterm"
+	"os"
+)
+
+// Plays the rpg in the terminal, it doesn't need SDL or a display:
+// go run ./rpg/rpgterm [-maps dir] [-seed n], or rpgterm [-addr host:port] [-name name] client
+// to join a game started with rpg server
+func main() {
+	flags := cli.Register("address of the server to join as a client")
+	flag.Parse()
+	opts := flags.Options()
+
+	if flag.Arg(0) == "client" {
+		client, _ := flags.Join(opts)
+		uiterm.NewUI(client.InputChan, client.LevelChan, os.Stdin, os.Stdout).Run()
+		return
+	}
+
+	rpg := flags.NewGame(1, opts)
+	flags.StartReplay(rpg)
+	go rpg.Run()
+	uiterm.NewUI(rpg.InputChan, rpg.LevelChans[0], os.Stdin, os.Stdout).Run()
+}
@@ -0,0 +1,19 @@
+package ui2d
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// the art, fonts and sounds shipped with the game, so a built binary doesn't depend on the directory it is started from
+//
+//go:embed assets
+var embeddedAssets embed.FS
+
+func DefaultAssets() fs.FS {
+	assets, err := fs.Sub(embeddedAssets, "assets")
+	if err != nil {
+		panic(err)
+	}
+	return assets
+}
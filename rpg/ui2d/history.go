@@ -0,0 +1,76 @@
+package ui2d
+
+import (
+	"fmt"
+	. "gameswithgo/rpg/game"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// DrawHistory covers the whole window with the game's message log, newest at the bottom,
+// ui.historyScroll is how many lines the view is scrolled up from the newest message
+func (ui *ui) DrawHistory(level *Level) {
+	ui.renderer.Copy(ui.eventBackground, nil, &sdl.Rect{0, 0, int32(ui.winWidth), int32(ui.winHeight)})
+
+	_, lineHeight, _ := ui.fontSmall.SizeUTF8("A")
+	padding := int32(5)
+	ui.drawText("Message log   up/down, page up/down or wheel to scroll, E to export, M or Esc to close", padding, padding)
+
+	top := padding + 2*int32(lineHeight)
+	visible := ui.historyLines()
+	messages := level.Log.Messages
+	ui.clampHistoryScroll(len(messages))
+
+	end := len(messages) - ui.historyScroll
+	start := end - visible
+	if start < 0 {
+		start = 0
+	}
+	for i, msg := range messages[start:end] {
+		text := fmt.Sprintf("turn %d  [%s]  %s", msg.Turn, msg.Kind, msg.Text)
+		ui.drawText(text, padding, top+int32(i*lineHeight))
+	}
+}
+
+// historyLines is how many messages fit on the screen below the title
+func (ui *ui) historyLines() int {
+	_, lineHeight, _ := ui.fontSmall.SizeUTF8("A")
+	lines := (ui.winHeight-10)/lineHeight - 2
+	if lines < 1 {
+		lines = 1
+	}
+	return lines
+}
+
+func (ui *ui) clampHistoryScroll(messages int) {
+	max := messages - ui.historyLines()
+	if ui.historyScroll > max {
+		ui.historyScroll = max
+	}
+	if ui.historyScroll < 0 {
+		ui.historyScroll = 0
+	}
+}
+
+// scrollHistory handles the keys of the history view, the mouse wheel is handled with the other sdl events
+func (ui *ui) scrollHistory(level *Level) {
+	page := ui.historyLines()
+	if ui.keyDownOnce(sdl.SCANCODE_UP) {
+		ui.historyScroll++
+	}
+	if ui.keyDownOnce(sdl.SCANCODE_DOWN) {
+		ui.historyScroll--
+	}
+	if ui.keyDownOnce(sdl.SCANCODE_PAGEUP) {
+		ui.historyScroll += page
+	}
+	if ui.keyDownOnce(sdl.SCANCODE_PAGEDOWN) {
+		ui.historyScroll -= page
+	}
+	if ui.keyDownOnce(sdl.SCANCODE_HOME) {
+		ui.historyScroll = len(level.Log.Messages)
+	}
+	if ui.keyDownOnce(sdl.SCANCODE_END) {
+		ui.historyScroll = 0
+	}
+	ui.clampHistoryScroll(len(level.Log.Messages))
+}
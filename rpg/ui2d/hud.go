@@ -0,0 +1,141 @@
+package ui2d
+
+import (
+	"fmt"
+	. "gameswithgo/rpg/game"
+	"github.com/veandco/go-sdl2/sdl"
+	"strconv"
+)
+
+const (
+	hudIconSize = 24
+	hudPadding  = 5
+)
+
+// hudRect is the box the HUD is drawn in, four lines of text and a row of icons
+func (ui *ui) hudRect() *sdl.Rect {
+	_, lineHeight, _ := ui.fontSmall.SizeUTF8("A")
+	return &sdl.Rect{0, 0, int32(float64(ui.winWidth) * .25), 4*int32(lineHeight) + hudIconSize + 3*hudPadding}
+}
+
+// DrawHUD shows where the player is, how it's doing and what it's wearing in the top left corner
+func (ui *ui) DrawHUD(level *Level) {
+	p := level.Player
+	stats := p.Stats()
+	hud := ui.hudRect()
+	hudWidth := hud.W
+	_, lineHeight, _ := ui.fontSmall.SizeUTF8("A")
+	iconSize := int32(hudIconSize)
+	padding := int32(hudPadding)
+	ui.renderer.Copy(ui.eventBackground, nil, hud)
+
+	y := padding
+	name := level.Name
+	if name == "" {
+		name = "somewhere"
+	}
+	ui.drawText(name+"   turn "+strconv.Itoa(p.Turns), padding, y)
+	y += int32(lineHeight)
+
+	// hp bar, the filled part shrinks from the right as the player gets hurt
+	barWidth := hudWidth - 2*padding
+	ui.renderer.Copy(ui.hpBarBackground, nil, &sdl.Rect{padding, y, barWidth, int32(lineHeight)})
+	if p.Hitpoints > 0 && p.MaxHitpoints > 0 {
+		filled := barWidth * int32(p.Hitpoints) / int32(p.MaxHitpoints)
+		if filled > barWidth {
+			filled = barWidth
+		}
+		ui.renderer.Copy(ui.hpBarFill, nil, &sdl.Rect{padding, y, filled, int32(lineHeight)})
+	}
+	ui.drawText("HP "+strconv.Itoa(p.Hitpoints)+"/"+strconv.Itoa(p.MaxHitpoints), padding*2, y)
+	y += int32(lineHeight)
+
+	ui.drawText("Level "+strconv.Itoa(p.ExpLevel)+"   XP "+strconv.Itoa(p.XP)+"/"+strconv.Itoa(XPForLevel(p.ExpLevel+1)), padding, y)
+	y += int32(lineHeight)
+	ui.drawText(fmt.Sprintf("ATK %d  DEF %d  SPD %g  SIGHT %d", stats.Attack, stats.Defense, stats.Speed, stats.SightRange), padding, y)
+	y += int32(lineHeight) + padding
+
+	for i, item := range p.Equipment() {
+		ui.drawRune(item.Rune, &sdl.Rect{padding + int32(i)*(iconSize+padding), y, iconSize, iconSize})
+	}
+}
+
+func (ui *ui) drawText(s string, x, y int32) {
+	tex := ui.stringToTexture(s, sdl.Color{255, 255, 255, 0}, FontSmall)
+	_, _, w, h, err := tex.Query()
+	if err != nil {
+		panic(err)
+	}
+	ui.renderer.Copy(tex, nil, &sdl.Rect{x, y, w, h})
+}
+
+// DrawTooltip describes the monster or item under the mouse, in the inventory, on the ground
+// bar or on a visible tile of the map
+func (ui *ui) DrawTooltip(level *Level) {
+	text := ui.hoveredText(level)
+	if text == "" {
+		return
+	}
+	tex := ui.stringToTexture(text, sdl.Color{255, 255, 255, 0}, FontSmall)
+	_, _, w, h, err := tex.Query()
+	if err != nil {
+		panic(err)
+	}
+	x := int32(ui.currMouseState.pos.X) + 16
+	y := int32(ui.currMouseState.pos.Y) + 16
+	// keep the tooltip inside the window
+	if x+w > int32(ui.winWidth) {
+		x = int32(ui.winWidth) - w
+	}
+	if y+h > int32(ui.winHeight) {
+		y = int32(ui.currMouseState.pos.Y) - h
+	}
+	ui.renderer.Copy(ui.slotBackground, nil, &sdl.Rect{x - 3, y - 3, w + 6, h + 6})
+	ui.renderer.Copy(tex, nil, &sdl.Rect{x, y, w, h})
+}
+
+func (ui *ui) hoveredText(level *Level) string {
+	mouse := &sdl.Rect{int32(ui.currMouseState.pos.X), int32(ui.currMouseState.pos.Y), 1, 1}
+	if ui.state == UIInventory {
+		for i, item := range level.Player.Items {
+			if ui.getInventoryItemRect(i).HasIntersection(mouse) {
+				return item.Describe()
+			}
+		}
+		for _, slot := range ui.equipmentSlots(&level.Player.Character) {
+			if slot.item != nil && slot.rect.HasIntersection(mouse) {
+				return slot.item.Describe()
+			}
+		}
+		if ui.getInventoryRect().HasIntersection(mouse) {
+			return ""
+		}
+	}
+	for i, item := range level.Items[level.Player.Pos] {
+		if ui.getGroundItemRect(i).HasIntersection(mouse) {
+			return item.Describe()
+		}
+	}
+
+	pos, onMap := ui.mouseMapPos(level)
+	if !onMap || !level.Map[pos.Y][pos.X].Visible {
+		return ""
+	}
+	if monster, exists := level.Monsters[pos]; exists {
+		return describeMonster(monster)
+	}
+	if items := level.Items[pos]; len(items) > 0 {
+		return items[len(items)-1].Describe()
+	}
+	return ""
+}
+
+func describeMonster(m *Monster) string {
+	stats := m.Stats()
+	text := fmt.Sprintf("%s  HP %d/%d  ATK %d  DEF %d  SPD %g", m.Name, m.Hitpoints, m.MaxHitpoints,
+		stats.Attack, stats.Defense, stats.Speed)
+	if m.Behaviour != nil {
+		text += "  (" + m.State.String() + ")"
+	}
+	return text
+}
@@ -1,9 +1,9 @@
 package ui2d
 
 import (
-	"fmt"
 	. "gameswithgo/rpg/game"
 	"github.com/veandco/go-sdl2/sdl"
+	"strconv"
 )
 
 func (ui *ui) DrawInventory(level *Level) {
@@ -13,22 +13,29 @@ func (ui *ui) DrawInventory(level *Level) {
 	offset := int32(float64(invRect.H) * .05)
 
 	ui.renderer.Copy(ui.textureAtlas, &playerSrcRect, &sdl.Rect{invRect.X + invRect.X/4, invRect.Y + offset, invRect.W / 2, invRect.H / 2})
-	ui.renderer.Copy(ui.slotBackground, nil, ui.getHelmetSlotRect())
-	if level.Player.Helmet != nil {
-		ui.renderer.Copy(ui.textureAtlas, &ui.textureIndex[level.Player.Helmet.Rune][0], ui.getHelmetSlotRect())
-	}
-	ui.renderer.Copy(ui.slotBackground, nil, ui.getWeaponSlotRect())
-	if level.Player.Weapon != nil {
-		ui.renderer.Copy(ui.textureAtlas, &ui.textureIndex[level.Player.Weapon.Rune][0], ui.getWeaponSlotRect())
+	for _, slot := range ui.equipmentSlots(&level.Player.Character) {
+		ui.renderer.Copy(ui.slotBackground, nil, slot.rect)
+		if slot.item != nil {
+			ui.drawRune(slot.item.Rune, slot.rect)
+		}
 	}
 
 	for i, item := range level.Player.Items {
-		itemSrcRect := ui.textureIndex[item.Rune][0]
 		if item == ui.draggedItem {
 			itemSize := int32(float32(ui.winWidth) * itemSizeRatio)
-			ui.renderer.Copy(ui.textureAtlas, &itemSrcRect, &sdl.Rect{int32(ui.currMouseState.pos.X), int32(ui.currMouseState.pos.Y), itemSize, itemSize})
+			ui.drawRune(item.Rune, &sdl.Rect{int32(ui.currMouseState.pos.X), int32(ui.currMouseState.pos.Y), itemSize, itemSize})
 		} else {
-			ui.renderer.Copy(ui.textureAtlas, &itemSrcRect, ui.getInventoryItemRect(i))
+			itemRect := ui.getInventoryItemRect(i)
+			ui.drawRune(item.Rune, itemRect)
+			if item.Count > 1 {
+				// stack size in the bottom right corner of the slot
+				tex := ui.stringToTexture(strconv.Itoa(item.Count), sdl.Color{255, 255, 255, 0}, FontSmall)
+				_, _, w, h, err := tex.Query()
+				if err != nil {
+					panic(err)
+				}
+				ui.renderer.Copy(tex, nil, &sdl.Rect{itemRect.X + itemRect.W - w, itemRect.Y + itemRect.H - h, w, h})
+			}
 		}
 	}
 }
@@ -47,6 +54,63 @@ func (ui *ui) getWeaponSlotRect() *sdl.Rect {
 	return &sdl.Rect{invRect.X + xOffset, invRect.Y + yOffset, slotSize, slotSize}
 }
 
+// the slots on the right mirror the ones on the left
+func (ui *ui) getShieldSlotRect() *sdl.Rect {
+	weapon := ui.getWeaponSlotRect()
+	invRect := ui.getInventoryRect()
+	return &sdl.Rect{invRect.X*2 + invRect.W - weapon.X - weapon.W, weapon.Y, weapon.W, weapon.H}
+}
+
+func (ui *ui) getAmuletSlotRect() *sdl.Rect {
+	return ui.getCenterSlotRect(.1)
+}
+
+func (ui *ui) getArmorSlotRect() *sdl.Rect {
+	return ui.getCenterSlotRect(.25)
+}
+
+func (ui *ui) getBootsSlotRect() *sdl.Rect {
+	return ui.getCenterSlotRect(.45)
+}
+
+// getRingSlotRect puts the first ring under the weapon and the second one under the shield
+func (ui *ui) getRingSlotRect(i int) *sdl.Rect {
+	r := ui.getWeaponSlotRect()
+	if i == 1 {
+		r = ui.getShieldSlotRect()
+	}
+	invRect := ui.getInventoryRect()
+	r.Y += int32(float64(invRect.H) * .17)
+	return r
+}
+
+// getCenterSlotRect is a slot in the middle of the inventory, yRatio of its height from the top
+func (ui *ui) getCenterSlotRect(yRatio float64) *sdl.Rect {
+	helmet := ui.getHelmetSlotRect()
+	invRect := ui.getInventoryRect()
+	return &sdl.Rect{helmet.X, invRect.Y + int32(float64(invRect.H)*yRatio), helmet.W, helmet.H}
+}
+
+type equipmentSlot struct {
+	rect *sdl.Rect
+	slot Slot
+	typ  ItemType
+	item *Item
+}
+
+func (ui *ui) equipmentSlots(c *Character) []equipmentSlot {
+	return []equipmentSlot{
+		{ui.getHelmetSlotRect(), HelmetSlot, Helmet, c.Helmet},
+		{ui.getAmuletSlotRect(), AmuletSlot, Amulet, c.Amulet},
+		{ui.getArmorSlotRect(), ArmorSlot, Armor, c.Armor},
+		{ui.getWeaponSlotRect(), WeaponSlot, Weapon, c.Weapon},
+		{ui.getShieldSlotRect(), ShieldSlot, Shield, c.Shield},
+		{ui.getRingSlotRect(0), LeftRingSlot, Ring, c.LeftRing},
+		{ui.getRingSlotRect(1), RightRingSlot, Ring, c.RightRing},
+		{ui.getBootsSlotRect(), BootsSlot, Boots, c.Boots},
+	}
+}
+
 func (ui *ui) getInventoryRect() *sdl.Rect {
 	invWidth := int32(float32(ui.winWidth) * .40)
 	invHeight := int32(float32(ui.winHeight) * .75)
@@ -61,19 +125,14 @@ func (ui *ui) getInventoryItemRect(i int) *sdl.Rect {
 	return &sdl.Rect{invRect.X + int32(i)*itemSize, invRect.Y + invRect.H - itemSize, itemSize, itemSize}
 }
 
-func (ui *ui) CheckEquippedItem() *Item {
-	if ui.draggedItem.Typ == Weapon {
-		r := ui.getWeaponSlotRect()
-		if r.HasIntersection(&sdl.Rect{int32(ui.currMouseState.pos.X), int32(ui.currMouseState.pos.Y), 1,1}) {
-			return ui.draggedItem
-		}
-	} else if ui.draggedItem.Typ == Helmet {
-		r := ui.getHelmetSlotRect()
-		if r.HasIntersection(&sdl.Rect{int32(ui.currMouseState.pos.X), int32(ui.currMouseState.pos.Y), 1,1}) {
-			return ui.draggedItem
+// CheckEquippedItem returns the dragged item and the slot it was let go over, if it fits in there
+func (ui *ui) CheckEquippedItem(level *Level) (*Item, Slot) {
+	for _, slot := range ui.equipmentSlots(&level.Player.Character) {
+		if slot.typ == ui.draggedItem.Typ && slot.rect.HasIntersection(&sdl.Rect{int32(ui.currMouseState.pos.X), int32(ui.currMouseState.pos.Y), 1, 1}) {
+			return ui.draggedItem, slot.slot
 		}
 	}
-	return nil
+	return nil, AnySlot
 }
 
 func (ui *ui) CheckDroppedItem() *Item {
@@ -92,7 +151,20 @@ func (ui *ui) CheckInventoryItems(level *Level) *Item {
 			itemRect := ui.getInventoryItemRect(i)
 			// checking if click occurs within the item's rect
 			if itemRect.HasIntersection(&sdl.Rect{int32(mousePos.X), int32(mousePos.Y), 1, 1}) {
-				fmt.Println("clicked inventory item!")
+				return item
+			}
+		}
+	}
+	return nil
+}
+
+// CheckUsedItem returns the backpack item that was right-clicked, if it can be used
+func (ui *ui) CheckUsedItem(level *Level) *Item {
+	if !ui.currMouseState.rightButton && ui.prevMouseState.rightButton {
+		mousePos := ui.currMouseState.pos
+		for i, item := range level.Player.Items {
+			itemRect := ui.getInventoryItemRect(i)
+			if item.Typ == Consumable && itemRect.HasIntersection(&sdl.Rect{int32(mousePos.X), int32(mousePos.Y), 1, 1}) {
 				return item
 			}
 		}
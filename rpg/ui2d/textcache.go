@@ -0,0 +1,44 @@
+package ui2d
+
+import (
+	"container/list"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// textCacheSize is how many rendered strings each font keeps around
+const textCacheSize = 256
+
+// textCache keeps the textures of the strings drawn lately. The HUD, the tooltips and the log keep
+// drawing new strings, so once the cache is full the one drawn longest ago is destroyed.
+type textCache struct {
+	max   int
+	order *list.List // most recently drawn in front
+	texs  map[string]*list.Element
+}
+
+type cachedText struct {
+	s   string
+	tex *sdl.Texture
+}
+
+func newTextCache(max int) *textCache {
+	return &textCache{max: max, order: list.New(), texs: make(map[string]*list.Element)}
+}
+
+func (c *textCache) get(s string) (*sdl.Texture, bool) {
+	e, exists := c.texs[s]
+	if !exists {
+		return nil, false
+	}
+	c.order.MoveToFront(e)
+	return e.Value.(*cachedText).tex, true
+}
+
+func (c *textCache) add(s string, tex *sdl.Texture) {
+	c.texs[s] = c.order.PushFront(&cachedText{s, tex})
+	for c.order.Len() > c.max {
+		oldest := c.order.Remove(c.order.Back()).(*cachedText)
+		delete(c.texs, oldest.s)
+		oldest.tex.Destroy()
+	}
+}
@@ -7,14 +7,42 @@ import (
 	"github.com/veandco/go-sdl2/sdl"
 	"github.com/veandco/go-sdl2/ttf"
 	"image/png"
+	"io/fs"
 	"math/rand"
-	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
 const itemSizeRatio = .033
 
+// arrows, numpad and vi-keys
+var moveKeys = map[uint8]InputType{
+	sdl.SCANCODE_UP:    Up,
+	sdl.SCANCODE_DOWN:  Down,
+	sdl.SCANCODE_LEFT:  Left,
+	sdl.SCANCODE_RIGHT: Right,
+	sdl.SCANCODE_KP_8:  Up,
+	sdl.SCANCODE_KP_2:  Down,
+	sdl.SCANCODE_KP_4:  Left,
+	sdl.SCANCODE_KP_6:  Right,
+	sdl.SCANCODE_KP_7:  UpLeft,
+	sdl.SCANCODE_KP_9:  UpRight,
+	sdl.SCANCODE_KP_1:  DownLeft,
+	sdl.SCANCODE_KP_3:  DownRight,
+	sdl.SCANCODE_K:     Up,
+	sdl.SCANCODE_J:     Down,
+	sdl.SCANCODE_H:     Left,
+	sdl.SCANCODE_L:     Right,
+	sdl.SCANCODE_Y:     UpLeft,
+	sdl.SCANCODE_U:     UpRight,
+	sdl.SCANCODE_B:     DownLeft,
+	sdl.SCANCODE_N:     DownRight,
+}
+
+// how long to wait between the steps of a TravelTo or Explore
+const travelStepDelay = 60 * time.Millisecond
+
 type mouseState struct {
 	leftButton  bool
 	rightButton bool
@@ -32,6 +60,8 @@ func getMouseState() *mouseState {
 	return &result
 }
 
+var audioOpen bool
+
 type sounds struct {
 	openingDoors []*mix.Chunk
 	footsteps    []*mix.Chunk
@@ -48,10 +78,19 @@ type uiState int
 const (
 	UIMain uiState = iota
 	UIInventory
+	UIHistory
 )
 
 type ui struct {
-	state uiState
+	state          uiState
+	historyScroll  int
+	lastTravelStep time.Time
+
+	spectator bool
+	level     *Level // the last level the game sent
+	closed    bool   // the game closed levelChan
+	opened    []*ui  // windows opened this frame, Run takes them over
+	defs      *Definitions
 
 	draggedItem *Item
 
@@ -82,23 +121,36 @@ type ui struct {
 	fontLarge  *ttf.Font
 
 	eventBackground           *sdl.Texture
+	hpBarBackground           *sdl.Texture
+	hpBarFill                 *sdl.Texture
 	groundInventoryBackground *sdl.Texture
 	slotBackground            *sdl.Texture
 
-	str2TexSmall map[string]*sdl.Texture
-	str2TexMed   map[string]*sdl.Texture
-	str2TexLarge map[string]*sdl.Texture
+	str2TexSmall *textCache
+	str2TexMed   *textCache
+	str2TexLarge *textCache
 
 	currMouseState *mouseState
 	prevMouseState *mouseState
+
+	assets fs.FS
+	// fonts and music are read lazily by SDL, so their bytes have to outlive the RWops
+	assetData [][]byte
 }
 
-func NewUI(inputChan chan *Input, levelChan chan *Level) *ui {
+// NewUI opens a window, assets == nil uses the assets built into the binary.
+// The tiles of monsters and items come from defs.
+func NewUI(inputChan chan *Input, levelChan chan *Level, assets fs.FS, defs *Definitions) *ui {
 	ui := &ui{}
+	if assets == nil {
+		assets = DefaultAssets()
+	}
+	ui.assets = assets
+	ui.defs = defs
 	ui.state = UIMain
-	ui.str2TexLarge = make(map[string]*sdl.Texture)
-	ui.str2TexMed = make(map[string]*sdl.Texture)
-	ui.str2TexSmall = make(map[string]*sdl.Texture)
+	ui.str2TexLarge = newTextCache(textCacheSize)
+	ui.str2TexMed = newTextCache(textCacheSize)
+	ui.str2TexSmall = newTextCache(textCacheSize)
 	ui.inputChan = inputChan
 	ui.levelChan = levelChan
 	ui.winHeight = 720
@@ -118,8 +170,9 @@ func NewUI(inputChan chan *Input, levelChan chan *Level) *ui {
 	// bilinear filtering through SDL
 	//sdl.SetHint(sdl.HINT_RENDER_SCALE_QUALITY, "1")
 
-	ui.textureAtlas = ui.imgFileToTexture("rpg/ui2d/assets/tiles.png")
+	ui.textureAtlas = ui.imgFileToTexture("tiles.png")
 	ui.loadTextureIndex()
+	ui.addDefinitionTiles(defs)
 
 	ui.keyboardState = sdl.GetKeyboardState()
 	ui.prevKeyboardState = make([]uint8, len(ui.keyboardState))
@@ -130,18 +183,18 @@ func NewUI(inputChan chan *Input, levelChan chan *Level) *ui {
 	ui.centerX = -1
 	ui.centerY = -1
 
-	ui.fontSmall, err = ttf.OpenFont("rpg/ui2d/assets/Kingthings_Foundation.ttf", int(float64(ui.winHeight)*.02))
-	//ui.fontSmall, err = ttf.OpenFont("rpg/ui2d/assets/Kingthings_Foundation.ttf", 18)
+	ui.fontSmall, err = ttf.OpenFontRW(ui.assetRW("Kingthings_Foundation.ttf"), 1, int(float64(ui.winHeight)*.02))
+	//ui.fontSmall, err = ttf.OpenFontRW(ui.assetRW("Kingthings_Foundation.ttf"), 1, 18)
 	if err != nil {
 		panic(err)
 	}
 
-	ui.fontMedium, err = ttf.OpenFont("rpg/ui2d/assets/Kingthings_Foundation.ttf", 32)
+	ui.fontMedium, err = ttf.OpenFontRW(ui.assetRW("Kingthings_Foundation.ttf"), 1, 32)
 	if err != nil {
 		panic(err)
 	}
 
-	ui.fontLarge, err = ttf.OpenFont("rpg/ui2d/assets/Kingthings_Foundation.ttf", 64)
+	ui.fontLarge, err = ttf.OpenFontRW(ui.assetRW("Kingthings_Foundation.ttf"), 1, 64)
 	if err != nil {
 		panic(err)
 	}
@@ -152,36 +205,43 @@ func NewUI(inputChan chan *Input, levelChan chan *Level) *ui {
 	ui.groundInventoryBackground = ui.GetSinglePixelTex(sdl.Color{149, 84, 19, 128})
 	ui.groundInventoryBackground.SetBlendMode(sdl.BLENDMODE_BLEND)
 
-	ui.slotBackground = ui.GetSinglePixelTex(sdl.Color{0,0,0,255})
+	ui.slotBackground = ui.GetSinglePixelTex(sdl.Color{0, 0, 0, 255})
 
-	err = mix.OpenAudio(22050, mix.DEFAULT_FORMAT, 2, 4096)
-	if err != nil {
-		panic(err)
-	}
-	mus, err := mix.LoadMUS("rpg/ui2d/assets/ambient.ogg")
-	if err != nil {
-		panic(err)
-	}
-	err = mus.Play(-1)
-	if err != nil {
-		panic(err)
+	ui.hpBarBackground = ui.GetSinglePixelTex(sdl.Color{120, 0, 0, 255})
+	ui.hpBarFill = ui.GetSinglePixelTex(sdl.Color{0, 160, 0, 255})
+
+	// every window shares the one audio device and its music
+	if !audioOpen {
+		err = mix.OpenAudio(22050, mix.DEFAULT_FORMAT, 2, 4096)
+		if err != nil {
+			panic(err)
+		}
+		mus, err := mix.LoadMUSRW(ui.assetRW("ambient.ogg"), 1)
+		if err != nil {
+			panic(err)
+		}
+		err = mus.Play(-1)
+		if err != nil {
+			panic(err)
+		}
+		audioOpen = true
 	}
 
-	footstepBase := "rpg/ui2d/assets/footstep0"
+	footstepBase := "footstep0"
 	for i := 0; i < 10; i++ {
 		footstepFile := footstepBase + strconv.Itoa(i) + ".ogg"
-		footstep, err := mix.LoadWAV(footstepFile)
+		footstep, err := mix.LoadWAVRW(ui.assetRW(footstepFile), true)
 		if err != nil {
 			panic(err)
 		}
 		ui.sounds.footsteps = append(ui.sounds.footsteps, footstep)
 	}
-	door1, err := mix.LoadWAV("rpg/ui2d/assets/doorOpen_1.ogg")
+	door1, err := mix.LoadWAVRW(ui.assetRW("doorOpen_1.ogg"), true)
 	if err != nil {
 		panic(err)
 	}
 	ui.sounds.openingDoors = append(ui.sounds.openingDoors, door1)
-	door2, err := mix.LoadWAV("rpg/ui2d/assets/doorOpen_2.ogg")
+	door2, err := mix.LoadWAVRW(ui.assetRW("doorOpen_2.ogg"), true)
 	if err != nil {
 		panic(err)
 	}
@@ -190,6 +250,14 @@ func NewUI(inputChan chan *Input, levelChan chan *Level) *ui {
 	return ui
 }
 
+// NewSpectator opens a window that only watches the game, levelChan must have joined the game as a spectator
+func NewSpectator(inputChan chan *Input, levelChan chan *Level, assets fs.FS, defs *Definitions) *ui {
+	ui := NewUI(inputChan, levelChan, assets, defs)
+	ui.spectator = true
+	ui.window.SetTitle("RPG!!! (spectating)")
+	return ui
+}
+
 type FontSize int
 
 const (
@@ -198,53 +266,40 @@ const (
 	FontLarge
 )
 
+// stringToTexture renders s, the texture belongs to the cache and is only good until the next call
+// has pushed it out, so draw it right away
 func (ui *ui) stringToTexture(s string, color sdl.Color, size FontSize) *sdl.Texture {
 	var font *ttf.Font
+	var cache *textCache
 	switch size {
 	case FontSmall:
-		font = ui.fontSmall
-		tex, exists := ui.str2TexSmall[s]
-		if exists {
-			return tex
-		}
+		font, cache = ui.fontSmall, ui.str2TexSmall
 	case FontMedium:
-		font = ui.fontMedium
-		tex, exists := ui.str2TexMed[s]
-		if exists {
-			return tex
-		}
+		font, cache = ui.fontMedium, ui.str2TexMed
 	case FontLarge:
-		font = ui.fontLarge
-		tex, exists := ui.str2TexLarge[s]
-		if exists {
-			return tex
-		}
+		font, cache = ui.fontLarge, ui.str2TexLarge
+	}
+	if tex, exists := cache.get(s); exists {
+		return tex
 	}
 
 	fontSurface, err := font.RenderUTF8Blended(s, color)
 	if err != nil {
 		panic(err)
 	}
+	defer fontSurface.Free()
 	tex, err := ui.renderer.CreateTextureFromSurface(fontSurface)
 	if err != nil {
 		panic(err)
 	}
 
-	switch size {
-	case FontSmall:
-		ui.str2TexSmall[s] = tex
-	case FontMedium:
-		ui.str2TexMed[s] = tex
-	case FontLarge:
-		ui.str2TexLarge[s] = tex
-	}
-
+	cache.add(s, tex)
 	return tex
 }
 
 func (ui *ui) loadTextureIndex() {
 	ui.textureIndex = make(map[rune][]sdl.Rect)
-	infile, err := os.Open("rpg/ui2d/assets/atlas-index.txt")
+	infile, err := ui.assets.Open("atlas-index.txt")
 	if err != nil {
 		panic(err)
 	}
@@ -283,8 +338,46 @@ func (ui *ui) loadTextureIndex() {
 	}
 }
 
+// drawRune copies the atlas tile of r to dst, monsters and items without a tile are drawn as their rune
+func (ui *ui) drawRune(r rune, dst *sdl.Rect) {
+	if srcRects := ui.textureIndex[r]; len(srcRects) > 0 {
+		ui.renderer.Copy(ui.textureAtlas, &srcRects[0], dst)
+		return
+	}
+	tex := ui.stringToTexture(string(r), sdl.Color{255, 255, 0, 0}, FontMedium)
+	ui.renderer.Copy(tex, nil, dst)
+}
+
+// addDefinitionTiles adds the atlas tiles of the monsters and items that name one to the texture index
+func (ui *ui) addDefinitionTiles(defs *Definitions) {
+	for _, def := range defs.Monsters {
+		if len(def.Tile) == 2 {
+			ui.textureIndex[rune(def.Rune)] = []sdl.Rect{{int32(def.Tile[0] * 32), int32(def.Tile[1] * 32), 32, 32}}
+		}
+	}
+	for _, def := range defs.Items {
+		if len(def.Tile) == 2 {
+			ui.textureIndex[rune(def.Rune)] = []sdl.Rect{{int32(def.Tile[0] * 32), int32(def.Tile[1] * 32), 32, 32}}
+		}
+	}
+}
+
+// assetRW wraps an asset file in an RWops for the SDL loaders
+func (ui *ui) assetRW(name string) *sdl.RWops {
+	data, err := fs.ReadFile(ui.assets, name)
+	if err != nil {
+		panic(err)
+	}
+	ui.assetData = append(ui.assetData, data)
+	rw, err := sdl.RWFromMem(data)
+	if err != nil {
+		panic(err)
+	}
+	return rw
+}
+
 func (ui *ui) imgFileToTexture(filename string) *sdl.Texture {
-	infile, err := os.Open(filename)
+	infile, err := ui.assets.Open(filename)
 	if err != nil {
 		panic(err)
 	}
@@ -349,6 +442,59 @@ func init() {
 	}
 }
 
+// mapOffset is where the top left corner of the map is drawn, used for camera movement
+func (ui *ui) mapOffset() (int32, int32) {
+	return int32((ui.winWidth / 2) - ui.centerX*32), int32((ui.winHeight / 2) - ui.centerY*32)
+}
+
+// mouseMapPos is the tile of the level under the mouse, tiles hidden by the HUD, the message log
+// or the ground items don't count
+func (ui *ui) mouseMapPos(level *Level) (Pos, bool) {
+	offsetX, offsetY := ui.mapOffset()
+	x, y := int32(ui.currMouseState.pos.X), int32(ui.currMouseState.pos.Y)
+	pos := Pos{int((x - offsetX) / 32), int((y - offsetY) / 32)}
+	if x < offsetX || y < offsetY || pos.Y >= len(level.Map) || pos.X >= len(level.Map[pos.Y]) || ui.overOverlay() {
+		return pos, false
+	}
+	return pos, true
+}
+
+// overOverlay tells whether the mouse is over one of the boxes drawn on top of the map
+func (ui *ui) overOverlay() bool {
+	mouse := &sdl.Rect{int32(ui.currMouseState.pos.X), int32(ui.currMouseState.pos.Y), 1, 1}
+	for _, rect := range []*sdl.Rect{ui.hudRect(), ui.logRect(), ui.groundInventoryRect()} {
+		if rect.HasIntersection(mouse) {
+			return true
+		}
+	}
+	return false
+}
+
+// logRect is the box the newest messages are drawn in, in the bottom left corner
+func (ui *ui) logRect() *sdl.Rect {
+	textStartY := int32(float64(ui.winHeight) * .68) // allows to add spacing between lines
+	return &sdl.Rect{0, textStartY, int32(float64(ui.winWidth) * .25), int32(ui.winHeight) - textStartY}
+}
+
+// groundInventoryRect is the bar the items under the player are drawn in, in the bottom right corner
+func (ui *ui) groundInventoryRect() *sdl.Rect {
+	groundInvStart := int32(float64(ui.winWidth) * .9)
+	itemSize := int32(itemSizeRatio * float32(ui.winWidth))
+	return &sdl.Rect{groundInvStart, int32(ui.winHeight) - itemSize, int32(ui.winWidth) - groundInvStart, itemSize}
+}
+
+// CheckTravel returns the seen tile the player clicked on
+func (ui *ui) CheckTravel(level *Level) (Pos, bool) {
+	if ui.currMouseState.leftButton || !ui.prevMouseState.leftButton {
+		return Pos{}, false
+	}
+	pos, onMap := ui.mouseMapPos(level)
+	if !onMap || !level.Map[pos.Y][pos.X].Seen {
+		return Pos{}, false
+	}
+	return pos, true
+}
+
 func (ui *ui) Draw(level *Level) {
 
 	if ui.centerX == -1 && ui.centerY == -1 {
@@ -374,9 +520,7 @@ func (ui *ui) Draw(level *Level) {
 		ui.centerY -= diff
 	}
 
-	// used for camera movement
-	offsetX := int32((ui.winWidth / 2) - ui.centerX*32)
-	offsetY := int32((ui.winHeight / 2) - ui.centerY*32)
+	offsetX, offsetY := ui.mapOffset()
 
 	ui.renderer.Clear()
 	ui.r.Seed(1)
@@ -400,8 +544,10 @@ func (ui *ui) Draw(level *Level) {
 					ui.renderer.Copy(ui.textureAtlas, &srcRect, &dstRect)
 					// TODO different variants for overlay images?
 					if tile.OverlayRune != Blank {
-						srcRect := ui.textureIndex[tile.OverlayRune][0]
-						ui.renderer.Copy(ui.textureAtlas, &srcRect, &dstRect)
+						ui.drawRune(tile.OverlayRune, &dstRect) // levers have no tile of their own
+					}
+					if level.FoundTrap(pos) != nil {
+						ui.drawRune(TrapRune, &dstRect)
 					}
 				}
 			}
@@ -410,9 +556,7 @@ func (ui *ui) Draw(level *Level) {
 	ui.textureAtlas.SetColorMod(255, 255, 255) // prevents monster being drawn greyed-out due to fog of war mechanic
 	for pos, monster := range level.Monsters {
 		if level.Map[pos.Y][pos.X].Visible {
-			monsterSrcRect := ui.textureIndex[monster.Rune][0]
-			ui.renderer.Copy(ui.textureAtlas, &monsterSrcRect,
-				&sdl.Rect{int32(pos.X)*32 + offsetX, int32(pos.Y)*32 + offsetY, 32, 32})
+			ui.drawRune(monster.Rune, &sdl.Rect{int32(pos.X)*32 + offsetX, int32(pos.Y)*32 + offsetY, 32, 32})
 		}
 	}
 
@@ -420,54 +564,79 @@ func (ui *ui) Draw(level *Level) {
 	for pos, items := range level.Items {
 		if level.Map[pos.Y][pos.X].Visible {
 			for _, item := range items {
-				itemSrcRect := ui.textureIndex[item.Rune][0]
-				ui.renderer.Copy(ui.textureAtlas, &itemSrcRect,
-					&sdl.Rect{int32(pos.X)*32 + offsetX, int32(pos.Y)*32 + offsetY, 32, 32})
+				ui.drawRune(item.Rune, &sdl.Rect{int32(pos.X)*32 + offsetX, int32(pos.Y)*32 + offsetY, 32, 32})
 			}
 		}
 	}
 
-	// Render Player
+	// Render the rest of the party, then the Player on top
+	for _, p := range level.Players {
+		if p != level.Player && p.Hitpoints > 0 && level.Map[p.Y][p.X].Visible {
+			ui.drawRune(p.Rune, &sdl.Rect{int32(p.X)*32 + offsetX, int32(p.Y)*32 + offsetY, 32, 32})
+		}
+	}
 	playerSrcRect := ui.textureIndex[level.Player.Rune][0]
 	ui.renderer.Copy(ui.textureAtlas, &playerSrcRect,
 		&sdl.Rect{int32(level.Player.X)*32 + offsetX, int32(level.Player.Y)*32 + offsetY, 32, 32})
 
 	// Events UI
-	textStartY := int32(float64(ui.winHeight) * .68) // allows to add spacing between lines
-	textWidth := int32(float64(ui.winWidth) * .25)
-
-	ui.renderer.Copy(ui.eventBackground, nil, &sdl.Rect{0, textStartY, textWidth, int32(ui.winHeight) - textStartY})
+	logRect := ui.logRect()
+	textStartY := logRect.Y
+	ui.renderer.Copy(ui.eventBackground, nil, logRect)
 
-	i := level.EventPos
-	count := 0
 	_, fontSizeY, _ := ui.fontSmall.SizeUTF8("A") // mosta letters have the same height but there are exceptions
-	for {
-		event := level.Events[i]
-		if event != "" {
-			tex := ui.stringToTexture(event, sdl.Color{255, 0, 0, 0}, FontSmall)
-			_, _, w, h, err := tex.Query()
-			if err != nil {
-				panic(err)
-			}
-			ui.renderer.Copy(tex, nil, &sdl.Rect{5, int32(count*fontSizeY) + textStartY, w, h})
-		}
-		i = (i + 1) % len(level.Events)
-		count++
-		if i == level.EventPos {
-			break
+	for count, msg := range level.Log.Last(10) {
+		tex := ui.stringToTexture(msg.Text, sdl.Color{255, 0, 0, 0}, FontSmall)
+		_, _, w, h, err := tex.Query()
+		if err != nil {
+			panic(err)
 		}
+		ui.renderer.Copy(tex, nil, &sdl.Rect{5, int32(count*fontSizeY) + textStartY, w, h})
 	}
 
 	// Inventory UI
-	groundInvStart := int32(float64(ui.winWidth) * .9)
-	groundInvWidth := int32(ui.winWidth) - groundInvStart
-	itemSize := int32(itemSizeRatio * float32(ui.winWidth))
-	ui.renderer.Copy(ui.groundInventoryBackground, nil,
-		&sdl.Rect{groundInvStart, int32(ui.winHeight) - itemSize, groundInvWidth, itemSize})
+	ui.renderer.Copy(ui.groundInventoryBackground, nil, ui.groundInventoryRect())
 	items := level.Items[level.Player.Pos]
 	for i, item := range items {
-		itemSrcRect := ui.textureIndex[item.Rune][0]
-		ui.renderer.Copy(ui.textureAtlas, &itemSrcRect, ui.getGroundItemRect(i))
+		ui.drawRune(item.Rune, ui.getGroundItemRect(i))
+	}
+
+	ui.DrawHUD(level)
+
+	if level.LastEvent == GameOver {
+		ui.DrawDeathScreen(level)
+	}
+}
+
+func (ui *ui) DrawDeathScreen(level *Level) {
+	ui.renderer.Copy(ui.eventBackground, nil, &sdl.Rect{0, 0, int32(ui.winWidth), int32(ui.winHeight)})
+
+	lines := []string{
+		"Turns survived: " + strconv.Itoa(level.Player.Turns),
+		"Monsters killed: " + strconv.Itoa(level.Player.Kills),
+	}
+	if level.Player.KilledBy != "" {
+		lines = append(lines, "Killed by: "+level.Player.KilledBy)
+	}
+	lines = append(lines, "Press R to restart")
+
+	tex := ui.stringToTexture("YOU DIED", sdl.Color{200, 0, 0, 0}, FontLarge)
+	_, _, w, h, err := tex.Query()
+	if err != nil {
+		panic(err)
+	}
+	y := int32(float64(ui.winHeight) * .3)
+	ui.renderer.Copy(tex, nil, &sdl.Rect{(int32(ui.winWidth) - w) / 2, y, w, h})
+	y += h * 2
+
+	for _, line := range lines {
+		tex := ui.stringToTexture(line, sdl.Color{255, 255, 255, 0}, FontMedium)
+		_, _, w, h, err := tex.Query()
+		if err != nil {
+			panic(err)
+		}
+		ui.renderer.Copy(tex, nil, &sdl.Rect{(int32(ui.winWidth) - w) / 2, y, w, h})
+		y += h
 	}
 }
 
@@ -500,9 +669,15 @@ func (ui *ui) GetSinglePixelTex(color sdl.Color) *sdl.Texture {
 	return tex
 }
 
+// Run shows the window, along with the spectator windows opened from it, until all are closed
 func (ui *ui) Run() {
-	var newLevel *Level
-	ui.prevMouseState = getMouseState()
+	runWindows(ui)
+}
+
+// runWindows drives any number of windows from one goroutine, SDL hands the events of every
+// window to the one event loop
+func runWindows(first *ui) {
+	windows := []*ui{first}
 	// Comment from YT
 	//I'm not sure if you discover this later, but for the keyboard events: the event has "Type" and "Repeat" members.  So, if "Type" is "sdl.KEYDOWN" and "Repeat" is 0, then this is the initial press of that key.
 	//
@@ -511,104 +686,249 @@ func (ui *ui) Run() {
 	//		<do stuff>
 	//	}
 	//}
-	for {
+	for len(windows) > 0 {
 		for event := sdl.PollEvent(); event != nil; event = sdl.PollEvent() {
-			switch e := event.(type) {
-			case *sdl.QuitEvent:
-				ui.inputChan <- &Input{Typ: QuitGame, LevelChannel: ui.levelChan}
-			case *sdl.WindowEvent:
-				if e.Event == sdl.WINDOWEVENT_CLOSE {
-					ui.inputChan <- &Input{Typ: CloseWindow}
-				}
+			for _, w := range windows {
+				w.handleEvent(event)
 			}
 		}
 
-		ui.currMouseState = getMouseState()
-		var input Input
-		var ok bool
+		var opened []*ui
+		open := windows[:0]
+		for _, w := range windows {
+			if w.frame() {
+				open = append(open, w)
+			} else {
+				w.destroy()
+			}
+			opened = append(opened, w.opened...)
+			w.opened = nil
+		}
+		windows = append(open, opened...)
+		sdl.Delay(20) // CPU is not getting eaten when waiting for inputs
+	}
+}
+
+func (ui *ui) handleEvent(event sdl.Event) {
+	id, err := ui.window.GetID()
+	if err != nil {
+		panic(err)
+	}
+	switch e := event.(type) {
+	case *sdl.QuitEvent:
+		ui.send(&Input{Typ: QuitGame})
+	case *sdl.WindowEvent:
+		if e.WindowID == id && e.Event == sdl.WINDOWEVENT_CLOSE {
+			ui.send(&Input{Typ: CloseWindow})
+		}
+	case *sdl.MouseWheelEvent:
+		if e.WindowID == id && ui.state == UIHistory {
+			ui.historyScroll += int(e.Y) * 3
+		}
+	}
+}
+
+// send hands input to the game, picking up levels while it waits. It returns false once the game
+// has closed the window's level channel, because the window left or the game is over.
+func (ui *ui) send(input *Input) bool {
+	if input.LevelChannel == nil {
+		input.LevelChannel = ui.levelChan
+	}
+	for !ui.closed {
 		select {
-		case newLevel, ok = <-ui.levelChan:
+		case ui.inputChan <- input:
+			return true
+		case level, ok := <-ui.levelChan:
 			if ok {
-				switch newLevel.LastEvent {
-				case Move:
-					playRandomSound(ui.sounds.footsteps, 16)
-				case DoorOpen:
-					playRandomSound(ui.sounds.openingDoors, 32)
-				default:
-					// add more sounds
-				}
+				ui.level = level
+			} else {
+				ui.closed = true
 			}
-		default:
 		}
-		ui.Draw(newLevel)
+	}
+	return false
+}
 
-		if ui.state == UIInventory {
-			// have we stopped dragging?
-			if ui.draggedItem != nil && !ui.currMouseState.leftButton && ui.prevMouseState.leftButton {
-				item := ui.CheckEquippedItem()
-				if item != nil {
-					input.Typ = EquipItem
-					input.Item = item
-				}
-				item = ui.CheckDroppedItem()
-				if item != nil {
-					input.Typ = DropItem
-					input.Item = item
-					ui.draggedItem = nil
-				}
+// AddSpectator opens another window that follows the player, levelChan must have joined the game
+// as a spectator. The window shows up once Run gets to it.
+func (ui *ui) AddSpectator(levelChan chan *Level) {
+	ui.opened = append(ui.opened, NewSpectator(ui.inputChan, levelChan, ui.assets, ui.defs))
+}
+
+func (ui *ui) openSpectator() {
+	levelChan := make(chan *Level, 1)
+	if ui.send(&Input{Typ: Spectate, LevelChannel: levelChan}) {
+		ui.AddSpectator(levelChan)
+	}
+}
+
+func (ui *ui) destroy() {
+	ui.renderer.Destroy()
+	ui.window.Destroy()
+}
+
+// frame draws the window once and sends whatever the player did to the game,
+// it returns false when the window should close
+func (ui *ui) frame() bool {
+	if ui.closed {
+		return false
+	}
+	ui.currMouseState = getMouseState()
+	if ui.prevMouseState == nil {
+		ui.prevMouseState = ui.currMouseState
+	}
+	var input Input
+	select {
+	case level, ok := <-ui.levelChan:
+		if !ok {
+			return false
+		}
+		ui.level = level
+		if !ui.spectator {
+			switch level.LastEvent {
+			case Move:
+				playRandomSound(ui.sounds.footsteps, 16)
+			case DoorOpen:
+				playRandomSound(ui.sounds.openingDoors, 32)
+			default:
+				// add more sounds
 			}
-			if !ui.currMouseState.leftButton || ui.draggedItem == nil {
-				ui.draggedItem = ui.CheckInventoryItems(newLevel)
+		}
+	default:
+	}
+	newLevel := ui.level
+	if newLevel == nil {
+		return true // nothing to show before the game sends the first level
+	}
+	if ui.spectator {
+		// spectators keep the player in the middle of the window
+		ui.centerX = newLevel.Player.X
+		ui.centerY = newLevel.Player.Y
+	}
+	ui.Draw(newLevel)
+
+	if ui.state == UIInventory {
+		// have we stopped dragging?
+		if ui.draggedItem != nil && !ui.currMouseState.leftButton && ui.prevMouseState.leftButton {
+			item, slot := ui.CheckEquippedItem(newLevel)
+			if item != nil {
+				input.Typ = EquipItem
+				input.ItemID = item.ID
+				input.Slot = slot
 			}
-			ui.DrawInventory(newLevel)
+			item = ui.CheckDroppedItem()
+			if item != nil {
+				input.Typ = DropItem
+				input.ItemID = item.ID
+				ui.draggedItem = nil
+			}
+		}
+		if !ui.currMouseState.leftButton || ui.draggedItem == nil {
+			ui.draggedItem = ui.CheckInventoryItems(newLevel)
+		}
+		if item := ui.CheckUsedItem(newLevel); item != nil {
+			input.Typ = UseItem
+			input.ItemID = item.ID
 		}
-		ui.renderer.Present()
+		ui.DrawInventory(newLevel)
+	}
+	if ui.state == UIHistory {
+		ui.DrawHistory(newLevel)
+	} else {
+		ui.DrawTooltip(newLevel)
+	}
+	if ui.spectator {
+		ui.drawText("Spectating", int32(ui.winWidth)-100, 5)
+	}
+	ui.renderer.Present()
+	if ui.spectator {
+		ui.prevMouseState = ui.currMouseState
+		return true
+	}
 
+	if ui.state != UIHistory {
 		item := ui.CheckGroundItems(newLevel)
 		if item != nil {
 			input.Typ = TakeItem
-			input.Item = item
+			input.ItemID = item.ID
+		} else if pos, ok := ui.CheckTravel(newLevel); ok && ui.state == UIMain {
+			input.Typ = TravelTo
+			input.Pos = pos
 		}
+	}
 
-		if sdl.GetKeyboardFocus() == ui.window || sdl.GetMouseFocus() == ui.window {
+	if sdl.GetKeyboardFocus() == ui.window || sdl.GetMouseFocus() == ui.window {
 
-			if ui.keyDownOnce(sdl.SCANCODE_UP) {
-				input.Typ = Up
-			}
-			if ui.keyDownOnce(sdl.SCANCODE_DOWN) {
-				input.Typ = Down
+		if ui.state == UIHistory {
+			ui.scrollHistory(newLevel)
+			if ui.keyDownOnce(sdl.SCANCODE_E) {
+				input.Typ = ExportLog
 			}
-			if ui.keyDownOnce(sdl.SCANCODE_LEFT) {
-				input.Typ = Left
+			if ui.keyDownOnce(sdl.SCANCODE_M) || ui.keyDownOnce(sdl.SCANCODE_ESCAPE) {
+				ui.state = UIMain
 			}
-			if ui.keyDownOnce(sdl.SCANCODE_RIGHT) {
-				input.Typ = Right
-			}
-			if ui.keyDownOnce(sdl.SCANCODE_T) {
-				input.Typ = TakeAll
-			}
-			if ui.keyDownOnce(sdl.SCANCODE_I) {
-				if ui.state == UIMain {
-					ui.state = UIInventory
-				} else {
-					ui.state = UIMain
-				}
-			}
-			//if ui.keyboardState[sdl.SCANCODE_S] == 0 && ui.prevKeyboardState[sdl.SCANCODE_S] != 0 {
-			//	input.Typ = Search
-			//}
+		} else if ui.keyDownOnce(sdl.SCANCODE_M) {
+			ui.state = UIHistory
+			ui.historyScroll = 0
+		}
+		// the arrows scroll the history instead of moving the player
+		playing := ui.state != UIHistory
 
-			for i, v := range ui.keyboardState {
-				ui.prevKeyboardState[i] = v
+		for key, typ := range moveKeys {
+			if playing && ui.keyDownOnce(key) {
+				input.Typ = typ
 			}
-
-			if input.Typ != None {
-				//fmt.Println("Sending input...")
-				ui.inputChan <- &input
+		}
+		if playing && ui.keyDownOnce(sdl.SCANCODE_T) {
+			input.Typ = TakeAll
+		}
+		if playing && ui.keyDownOnce(sdl.SCANCODE_X) {
+			input.Typ = Explore
+		}
+		if ui.keyDownOnce(sdl.SCANCODE_R) && newLevel.LastEvent == GameOver {
+			input.Typ = Restart
+			ui.centerX = -1
+			ui.centerY = -1
+			ui.state = UIMain
+		}
+		if ui.keyDownOnce(sdl.SCANCODE_F5) {
+			input.Typ = SaveGame
+		}
+		if ui.keyDownOnce(sdl.SCANCODE_F9) {
+			input.Typ = LoadGame
+		}
+		if ui.keyDownOnce(sdl.SCANCODE_F2) {
+			ui.openSpectator()
+		}
+		if playing && ui.keyDownOnce(sdl.SCANCODE_I) {
+			if ui.state == UIMain {
+				ui.state = UIInventory
+			} else {
+				ui.state = UIMain
 			}
 		}
-		ui.prevMouseState = ui.currMouseState
-		sdl.Delay(20) // CPU is not getting eaten when waiting for inputs
+		if playing && ui.keyDownOnce(sdl.SCANCODE_S) {
+			input.Typ = Search
+		}
+
+		for i, v := range ui.keyboardState {
+			ui.prevKeyboardState[i] = v
+		}
+	} else {
+		input.Typ = None // clicks only count in the window that has the focus
+	}
+
+	// keep a journey going a step at a time so it can be watched and interrupted, also while
+	// the window is in the background
+	p := newLevel.Player
+	if input.Typ == None && (len(p.Path) > 0 || p.Exploring) && time.Since(ui.lastTravelStep) > travelStepDelay {
+		input.Typ = Travel
+		ui.lastTravelStep = time.Now()
+	}
 
+	if input.Typ != None {
+		ui.send(&input)
 	}
+	ui.prevMouseState = ui.currMouseState
+	return !ui.closed
 }
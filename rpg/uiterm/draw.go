@@ -0,0 +1,283 @@
+package uiterm
+
+import (
+	"fmt"
+	. "gameswithgo/rpg/game"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	reset  = "\x1b[0m"
+	faded  = "\x1b[90m" // seen before but out of sight right now
+	bold   = "\x1b[1m"
+	red    = "\x1b[31m"
+	yellow = "\x1b[33m"
+	cyan   = "\x1b[36m"
+)
+
+// draw repaints the whole terminal from the top left, clearing what is left of each line
+func (ui *ui) draw() {
+	if ui.level == nil {
+		return
+	}
+	var lines []string
+	switch {
+	case ui.level.LastEvent == GameOver:
+		lines = ui.deathScreen(ui.level)
+	case ui.state == UIHistory:
+		lines = ui.historyScreen(ui.level)
+	case ui.state == UIInventory:
+		lines = ui.inventoryScreen(ui.level)
+	default:
+		lines = ui.mainScreen(ui.level)
+	}
+	if len(lines) > ui.height {
+		lines = lines[:ui.height]
+	}
+	ui.out.WriteString("\x1b[H")
+	for i, line := range lines {
+		ui.out.WriteString(line)
+		ui.out.WriteString(reset + "\x1b[K")
+		if i < len(lines)-1 {
+			ui.out.WriteString("\r\n") // raw mode doesn't return the cursor on a new line
+		}
+	}
+	ui.out.WriteString("\x1b[J")
+	ui.out.Flush()
+}
+
+// mainScreen is a status line and the gear on top, the map around the player and the newest messages below
+func (ui *ui) mainScreen(level *Level) []string {
+	p := level.Player
+	stats := p.Stats()
+	name := level.Name
+	if name == "" {
+		name = "somewhere"
+	}
+	lines := []string{
+		ui.fit(fmt.Sprintf("%s  turn %d  HP %d/%d  Level %d  XP %d/%d  ATK %d  DEF %d  SPD %g  SIGHT %d",
+			name, p.Turns, p.Hitpoints, p.MaxHitpoints, p.ExpLevel, p.XP, XPForLevel(p.ExpLevel+1),
+			stats.Attack, stats.Defense, stats.Speed, stats.SightRange)),
+		ui.fit(gear(&p.Character) + "   i inventory, m messages, t take, s search, x explore, Q quit"),
+	}
+
+	mapHeight := ui.height - len(lines) - logLines
+	if mapHeight < 1 {
+		mapHeight = 1
+	}
+	lines = append(lines, ui.drawMap(level, mapHeight)...)
+
+	for _, msg := range level.Log.Last(logLines) {
+		lines = append(lines, ui.fit(msg.Text))
+	}
+	return lines
+}
+
+func gear(c *Character) string {
+	var names []string
+	for _, item := range c.Equipment() {
+		names = append(names, item.Name)
+	}
+	if len(names) == 0 {
+		return "wearing nothing"
+	}
+	return "wearing " + strings.Join(names, ", ")
+}
+
+// drawMap draws the part of the level around the player that fits in height lines,
+// tiles only remembered from before are faded and monsters only show up in sight
+func (ui *ui) drawMap(level *Level, height int) []string {
+	p := level.Player
+	left := viewStart(p.X, ui.width, len(level.Map[0]))
+	top := viewStart(p.Y, height, len(level.Map))
+
+	others := make(map[Pos]*Player)
+	for _, other := range level.Players {
+		if other != p && other.Hitpoints > 0 {
+			others[other.Pos] = other
+		}
+	}
+
+	var lines []string
+	for y := top; y < top+height && y < len(level.Map); y++ {
+		var sb strings.Builder
+		color := ""
+		for x := left; x < left+ui.width && x < len(level.Map[y]); x++ {
+			r, c := ui.cell(level, others, Pos{X: x, Y: y})
+			if c != color {
+				sb.WriteString(reset + c)
+				color = c
+			}
+			sb.WriteRune(r)
+		}
+		lines = append(lines, sb.String())
+	}
+	return lines
+}
+
+// cell is what to draw at pos and in which color
+func (ui *ui) cell(level *Level, others map[Pos]*Player, pos Pos) (rune, string) {
+	tile := level.Map[pos.Y][pos.X]
+	if !tile.Visible && !tile.Seen || tile.Rune == Blank {
+		return ' ', ""
+	}
+	r := tile.Rune
+	if tile.OverlayRune != Blank {
+		r = tile.OverlayRune
+	}
+	if level.FoundTrap(pos) != nil {
+		r = TrapRune
+	}
+	if !tile.Visible {
+		return r, faded
+	}
+	if pos == level.Player.Pos {
+		return level.Player.Rune, bold
+	}
+	if other := others[pos]; other != nil {
+		return other.Rune, cyan
+	}
+	if monster := level.Monsters[pos]; monster != nil {
+		return monster.Rune, red
+	}
+	if items := level.Items[pos]; len(items) > 0 {
+		return items[len(items)-1].Rune, yellow
+	}
+	return r, ""
+}
+
+// viewStart is the first row or column to show so that pos is in the middle of size of them,
+// without scrolling past the edges of the level
+func viewStart(pos, size, levelSize int) int {
+	start := pos - size/2
+	if start > levelSize-size {
+		start = levelSize - size
+	}
+	if start < 0 {
+		start = 0
+	}
+	return start
+}
+
+// inventoryScreen lists the backpack, the gear and what lies on the ground with the keys for them
+func (ui *ui) inventoryScreen(level *Level) []string {
+	p := level.Player
+	var backpack, wearing, ground []string
+	for i, item := range p.Items {
+		if i >= 26 {
+			backpack = append(backpack, fmt.Sprintf("... and %d more", len(p.Items)-i))
+			break
+		}
+		backpack = append(backpack, fmt.Sprintf("%c) %s", 'a'+i, item.Describe()))
+	}
+	for _, item := range p.Equipment() {
+		wearing = append(wearing, "   "+item.Describe())
+	}
+	for i, item := range level.Items[p.Pos] {
+		if i >= 9 {
+			break
+		}
+		ground = append(ground, fmt.Sprintf("%d) %s", i+1, item.Describe()))
+	}
+
+	lines := []string{ui.fit("Inventory   a-z use or equip, A-Z drop, 1-9 pick up, Esc to close")}
+	lines = append(lines, ui.section("Backpack", backpack)...)
+	lines = append(lines, ui.section("Wearing", wearing)...)
+	return append(lines, ui.section("On the ground", ground)...)
+}
+
+func (ui *ui) section(title string, entries []string) []string {
+	lines := []string{"", title}
+	if len(entries) == 0 {
+		entries = []string{"nothing"}
+	}
+	for _, entry := range entries {
+		lines = append(lines, ui.fit("  "+entry))
+	}
+	return lines
+}
+
+// historyScreen covers the terminal with the message log, newest at the bottom,
+// ui.historyScroll is how many lines the view is scrolled up from the newest message
+func (ui *ui) historyScreen(level *Level) []string {
+	lines := []string{ui.fit("Message log   up/down, page up/down, home/end to scroll, e to export, m or Esc to close"), ""}
+	messages := level.Log.Messages
+	ui.clampHistoryScroll(len(messages))
+
+	end := len(messages) - ui.historyScroll
+	start := end - ui.historyLines()
+	if start < 0 {
+		start = 0
+	}
+	for _, msg := range messages[start:end] {
+		lines = append(lines, ui.fit(fmt.Sprintf("turn %d  [%s]  %s", msg.Turn, msg.Kind, msg.Text)))
+	}
+	return lines
+}
+
+// historyLines is how many messages fit below the title
+func (ui *ui) historyLines() int {
+	if ui.height > 3 {
+		return ui.height - 2
+	}
+	return 1
+}
+
+func (ui *ui) clampHistoryScroll(messages int) {
+	max := messages - ui.historyLines()
+	if ui.historyScroll > max {
+		ui.historyScroll = max
+	}
+	if ui.historyScroll < 0 {
+		ui.historyScroll = 0
+	}
+}
+
+// scrollHistory handles the keys of the history view
+func (ui *ui) scrollHistory(k key) {
+	page := ui.historyLines()
+	switch k {
+	case keyUp, 'k':
+		ui.historyScroll++
+	case keyDown, 'j':
+		ui.historyScroll--
+	case keyPgUp:
+		ui.historyScroll += page
+	case keyPgDn:
+		ui.historyScroll -= page
+	case keyHome:
+		ui.historyScroll = len(ui.level.Log.Messages)
+	case keyEnd:
+		ui.historyScroll = 0
+	case 'e':
+		ui.send(&Input{Typ: ExportLog})
+	case 'm', keyEsc:
+		ui.state = UIMain
+	}
+	ui.clampHistoryScroll(len(ui.level.Log.Messages))
+}
+
+func (ui *ui) deathScreen(level *Level) []string {
+	p := level.Player
+	lines := []string{
+		"",
+		red + bold + "YOU DIED" + reset,
+		"",
+		"Turns survived: " + strconv.Itoa(p.Turns),
+		"Monsters killed: " + strconv.Itoa(p.Kills),
+	}
+	if p.KilledBy != "" {
+		lines = append(lines, "Killed by: "+p.KilledBy)
+	}
+	return append(lines, "", "Press r to restart or Q to quit")
+}
+
+// fit cuts s to the width of the terminal
+func (ui *ui) fit(s string) string {
+	if utf8.RuneCountInString(s) <= ui.width {
+		return s
+	}
+	return string([]rune(s)[:ui.width])
+}
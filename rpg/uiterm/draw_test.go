@@ -0,0 +1,41 @@
+package uiterm
+
+import "testing"
+
+func TestViewStart(t *testing.T) {
+	tests := []struct {
+		pos, size, levelSize int
+		want                 int
+	}{
+		{pos: 40, size: 20, levelSize: 100, want: 30},
+		{pos: 3, size: 20, levelSize: 100, want: 0},   // stops at the top or left edge
+		{pos: 98, size: 20, levelSize: 100, want: 80}, // and at the bottom or right edge
+		{pos: 5, size: 20, levelSize: 10, want: 0},    // a level smaller than the screen starts at 0
+		{pos: 10, size: 21, levelSize: 100, want: 0},
+	}
+	for _, test := range tests {
+		if got := viewStart(test.pos, test.size, test.levelSize); got != test.want {
+			t.Errorf("viewStart(%d, %d, %d) = %d, want %d", test.pos, test.size, test.levelSize, got, test.want)
+		}
+	}
+}
+
+func TestFit(t *testing.T) {
+	tests := []struct {
+		width int
+		in    string
+		want  string
+	}{
+		{5, "short", "short"},
+		{5, "longer", "longe"},
+		{3, "äöüß", "äöü"}, // cut in runes, not bytes
+		{10, "", ""},
+		{0, "x", ""},
+	}
+	for _, test := range tests {
+		ui := &ui{width: test.width}
+		if got := ui.fit(test.in); got != test.want {
+			t.Errorf("fit(%q) at width %d = %q, want %q", test.in, test.width, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package uiterm
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// key is a typed rune or, when negative, one of the keys below
+type key rune
+
+const (
+	keyUp key = -(iota + 1)
+	keyDown
+	keyLeft
+	keyRight
+	keyPgUp
+	keyPgDn
+	keyHome
+	keyEnd
+	keyF5
+	keyF9
+	keyEsc
+	keyCtrlC key = 3
+)
+
+// the escape sequences of the usual terminals without the leading ESC
+var escapes = map[string]key{
+	"[A": keyUp, "[B": keyDown, "[C": keyRight, "[D": keyLeft,
+	"OA": keyUp, "OB": keyDown, "OC": keyRight, "OD": keyLeft,
+	"[5~": keyPgUp, "[6~": keyPgDn,
+	"[H": keyHome, "[1~": keyHome, "OH": keyHome,
+	"[F": keyEnd, "[4~": keyEnd, "OF": keyEnd,
+	"[15~": keyF5, "[20~": keyF9,
+}
+
+// parseKeys splits what one read from the terminal returned into keys, an ESC on its own is the escape key
+// and escape sequences nobody knows are dropped
+func parseKeys(b []byte) []key {
+	var keys []key
+	for len(b) > 0 {
+		if b[0] == 0x1b {
+			if len(b) == 1 {
+				keys = append(keys, keyEsc)
+				return keys
+			}
+			n := escapeLen(b[1:])
+			if k, ok := escapes[string(b[1:1+n])]; ok {
+				keys = append(keys, k)
+			}
+			b = b[1+n:]
+			continue
+		}
+		r, size := utf8.DecodeRune(b)
+		keys = append(keys, key(r))
+		b = b[size:]
+	}
+	return keys
+}
+
+// escapeLen is the length of the escape sequence at the start of b: CSI sequences end with a byte
+// from @ to ~, SS3 ones are a single character
+func escapeLen(b []byte) int {
+	switch b[0] {
+	case '[':
+		for i := 1; i < len(b); i++ {
+			if b[i] >= 0x40 && b[i] <= 0x7e {
+				return i + 1
+			}
+		}
+		return len(b)
+	case 'O':
+		if len(b) > 1 {
+			return 2
+		}
+	}
+	return 1
+}
+
+// readKeys sends the keys typed on in until it can't read any more, then closes keys
+func readKeys(in io.Reader, keys chan<- key) {
+	defer close(keys)
+	buf := make([]byte, 64)
+	for {
+		n, err := in.Read(buf)
+		for _, k := range parseKeys(buf[:n]) {
+			keys <- k
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// rawMode switches the terminal to reading single keys without echoing them and returns how to
+// switch it back. stty is used so no terminal library is needed, if there is no terminal the keys
+// only arrive after Enter.
+func rawMode() (restore func()) {
+	saved, err := stty("-g")
+	if err != nil {
+		return func() {}
+	}
+	if _, err := stty("raw", "-echo"); err != nil {
+		return func() {}
+	}
+	return func() { stty(strings.TrimSpace(saved)) }
+}
+
+// terminalSize asks stty for the size of the terminal, the LINES and COLUMNS variables or assumes 80x24
+func terminalSize() (width, height int) {
+	width, height = 80, 24
+	if out, err := stty("size"); err == nil {
+		fields := strings.Fields(out)
+		if len(fields) == 2 {
+			h, errH := strconv.Atoi(fields[0])
+			w, errW := strconv.Atoi(fields[1])
+			if errH == nil && errW == nil && w > 0 && h > 0 {
+				return w, h
+			}
+		}
+	}
+	if w, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && w > 0 {
+		width = w
+	}
+	if h, err := strconv.Atoi(os.Getenv("LINES")); err == nil && h > 0 {
+		height = h
+	}
+	return width, height
+}
+
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return string(out), err
+}
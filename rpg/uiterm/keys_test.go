@@ -0,0 +1,50 @@
+package uiterm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseKeys(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []key
+	}{
+		{"", nil},
+		{"q", []key{'q'}},
+		{"hjé", []key{'h', 'j', 'é'}},
+		{"\x1b", []key{keyEsc}},
+		{"\x1b[A", []key{keyUp}},
+		{"\x1bOD", []key{keyLeft}},
+		{"\x1b[5~\x1b[6~", []key{keyPgUp, keyPgDn}},
+		{"a\x1b[15~b", []key{'a', keyF5, 'b'}},
+		{"\x1b[99~x", []key{'x'}}, // unknown sequences are dropped
+		{"\x1b[1", nil},           // cut off in the middle
+		{"\x03", []key{keyCtrlC}},
+	}
+	for _, test := range tests {
+		if got := parseKeys([]byte(test.in)); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parseKeys(%q) = %v, want %v", test.in, got, test.want)
+		}
+	}
+}
+
+func TestEscapeLen(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+	}{
+		{"[A", 2},
+		{"[15~", 4},
+		{"[1;5C", 5},
+		{"[12", 3}, // no final byte, the rest of the read
+		{"OB", 2},
+		{"O", 1},
+		{"x", 1},
+	}
+	for _, test := range tests {
+		if got := escapeLen([]byte(test.in)); got != test.want {
+			t.Errorf("escapeLen(%q) = %d, want %d", test.in, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+// Package uiterm plays the rpg in a terminal with ANSI escapes, for when there is no display:
+// over ssh or in a container. It talks to the game over the same channels as ui2d.
+package uiterm
+
+import (
+	"bufio"
+	. "gameswithgo/rpg/game"
+	"io"
+	"os"
+	"time"
+)
+
+// how long to wait between the steps of an Explore
+const travelStepDelay = 60 * time.Millisecond
+
+// lines below the map showing the newest messages
+const logLines = 5
+
+type uiState int
+
+const (
+	UIMain uiState = iota
+	UIInventory
+	UIHistory
+)
+
+// arrows, the digits of the numpad and vi-keys
+var moveKeys = map[key]InputType{
+	keyUp:    Up,
+	keyDown:  Down,
+	keyLeft:  Left,
+	keyRight: Right,
+	'8':      Up,
+	'2':      Down,
+	'4':      Left,
+	'6':      Right,
+	'7':      UpLeft,
+	'9':      UpRight,
+	'1':      DownLeft,
+	'3':      DownRight,
+	'k':      Up,
+	'j':      Down,
+	'h':      Left,
+	'l':      Right,
+	'y':      UpLeft,
+	'u':      UpRight,
+	'b':      DownLeft,
+	'n':      DownRight,
+}
+
+type ui struct {
+	state         uiState
+	historyScroll int
+
+	level  *Level // the last level the game sent
+	closed bool   // the game closed levelChan
+
+	width  int
+	height int
+
+	in        io.Reader
+	out       *bufio.Writer
+	levelChan chan *Level
+	inputChan chan *Input
+}
+
+// NewUI reads the keys from in and draws to out, which has to understand ANSI escapes. Reading
+// from os.Stdin switches the terminal to single keys while Run runs.
+func NewUI(inputChan chan *Input, levelChan chan *Level, in io.Reader, out io.Writer) *ui {
+	ui := &ui{inputChan: inputChan, levelChan: levelChan, in: in, out: bufio.NewWriter(out)}
+	ui.width, ui.height = terminalSize()
+	return ui
+}
+
+// Run plays until the player quits or the game closes the level channel
+func (ui *ui) Run() {
+	if ui.in == os.Stdin {
+		restore := rawMode()
+		defer restore()
+	}
+	// the alternate screen keeps the shell's scrollback clean, the cursor would only flicker over the map
+	ui.out.WriteString("\x1b[?1049h\x1b[?25l")
+	defer func() {
+		ui.out.WriteString("\x1b[0m\x1b[?25h\x1b[?1049l")
+		ui.out.Flush()
+	}()
+
+	keys := make(chan key, 16)
+	go readKeys(ui.in, keys)
+	ticker := time.NewTicker(travelStepDelay)
+	defer ticker.Stop()
+
+	for !ui.closed {
+		select {
+		case level, ok := <-ui.levelChan:
+			if !ok {
+				return
+			}
+			ui.level = level
+			ui.draw()
+		case k, ok := <-keys:
+			if !ok {
+				ui.send(&Input{Typ: QuitGame}) // nothing more to read, nobody is playing any more
+				return
+			}
+			if !ui.handleKey(k) {
+				return
+			}
+			ui.draw()
+		case <-ticker.C:
+			// keep a journey going a step at a time so it can be watched and interrupted
+			if ui.level != nil && ui.state == UIMain {
+				p := ui.level.Player
+				if len(p.Path) > 0 || p.Exploring {
+					ui.send(&Input{Typ: Travel})
+				}
+			}
+		}
+	}
+}
+
+// handleKey sends the game what the key means on the current screen, it returns false after quitting
+func (ui *ui) handleKey(k key) bool {
+	level := ui.level
+	if level == nil {
+		return true
+	}
+	switch k {
+	case keyCtrlC:
+		ui.send(&Input{Typ: QuitGame})
+		return false
+	case keyF5:
+		ui.send(&Input{Typ: SaveGame})
+		return true
+	case keyF9:
+		ui.send(&Input{Typ: LoadGame})
+		return true
+	}
+	if level.LastEvent == GameOver {
+		switch k {
+		case 'r':
+			ui.state = UIMain
+			ui.send(&Input{Typ: Restart})
+		case 'Q':
+			ui.send(&Input{Typ: QuitGame})
+			return false
+		}
+		return true
+	}
+
+	switch ui.state {
+	case UIHistory:
+		ui.scrollHistory(k)
+	case UIInventory:
+		ui.inventoryKey(k)
+	default:
+		if k == 'Q' {
+			ui.send(&Input{Typ: QuitGame})
+			return false
+		}
+		if typ, ok := moveKeys[k]; ok {
+			ui.send(&Input{Typ: typ})
+		}
+		switch k {
+		case 't':
+			ui.send(&Input{Typ: TakeAll})
+		case 's':
+			ui.send(&Input{Typ: Search})
+		case 'x':
+			ui.send(&Input{Typ: Explore})
+		case 'i':
+			ui.state = UIInventory
+		case 'm':
+			ui.state = UIHistory
+			ui.historyScroll = 0
+		case 'S': // for terminals without function keys
+			ui.send(&Input{Typ: SaveGame})
+		case 'L':
+			ui.send(&Input{Typ: LoadGame})
+		}
+	}
+	return true
+}
+
+// inventoryKey handles the inventory screen: a letter uses or equips an item from the backpack,
+// the same letter in upper case drops it and a digit picks up an item from the ground. Only Esc
+// closes it, every letter stands for an item.
+func (ui *ui) inventoryKey(k key) {
+	p := ui.level.Player
+	switch {
+	case k == keyEsc:
+		ui.state = UIMain
+	case k >= 'a' && k <= 'z':
+		if item := itemAt(p.Items, int(k-'a')); item != nil {
+			typ := EquipItem
+			if item.Typ == Consumable {
+				typ = UseItem
+			}
+			ui.send(&Input{Typ: typ, ItemID: item.ID})
+		}
+	case k >= 'A' && k <= 'Z':
+		if item := itemAt(p.Items, int(k-'A')); item != nil {
+			ui.send(&Input{Typ: DropItem, ItemID: item.ID})
+		}
+	case k >= '1' && k <= '9':
+		if item := itemAt(ui.level.Items[p.Pos], int(k-'1')); item != nil {
+			ui.send(&Input{Typ: TakeItem, ItemID: item.ID})
+		}
+	}
+}
+
+func itemAt(items []*Item, i int) *Item {
+	if i < len(items) {
+		return items[i]
+	}
+	return nil
+}
+
+// send hands input to the game, picking up levels while it waits
+func (ui *ui) send(input *Input) bool {
+	if input.LevelChannel == nil {
+		input.LevelChannel = ui.levelChan
+	}
+	for !ui.closed {
+		select {
+		case ui.inputChan <- input:
+			return true
+		case level, ok := <-ui.levelChan:
+			if ok {
+				ui.level = level
+			} else {
+				ui.closed = true
+			}
+		}
+	}
+	return false
+}
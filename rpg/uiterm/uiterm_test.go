@@ -0,0 +1,32 @@
+package uiterm
+
+import (
+	. "gameswithgo/rpg/game"
+	"testing"
+)
+
+// the ninth item of the backpack is labelled i), the key must use it instead of closing the inventory
+func TestInventoryNinthItem(t *testing.T) {
+	p := &Player{}
+	for i := 0; i < 9; i++ {
+		p.Items = append(p.Items, &Item{Typ: Consumable, Entity: Entity{ID: EntityID(i + 1)}, Count: 1})
+	}
+	inputs := make(chan *Input, 1)
+	ui := &ui{state: UIInventory, level: &Level{Player: p}, inputChan: inputs, levelChan: make(chan *Level, 1)}
+	ui.inventoryKey('i')
+	if ui.state != UIInventory {
+		t.Error("i closed the inventory")
+	}
+	select {
+	case input := <-inputs:
+		if input.Typ != UseItem || input.ItemID != 9 {
+			t.Errorf("i sent %v for item %d, want UseItem for item 9", input.Typ, input.ItemID)
+		}
+	default:
+		t.Error("i didn't use the ninth item")
+	}
+	ui.inventoryKey(keyEsc)
+	if ui.state != UIMain {
+		t.Error("Esc didn't close the inventory")
+	}
+}